@@ -0,0 +1,51 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned when the requested blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores the uploaded documents
+type BlobStore interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns the content stored under key, the caller has to close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key
+	Delete(ctx context.Context, key string) error
+}
+
+// Config defines the blob store configuration options
+type Config struct {
+	Backend     string `envconfig:"document_store" default:"local"`
+	Dir         string `envconfig:"document_dir" default:"documents"`
+	S3Endpoint  string `envconfig:"document_s3_endpoint"`
+	S3Region    string `envconfig:"document_s3_region" default:"us-east-1"`
+	S3Bucket    string `envconfig:"document_s3_bucket"`
+	S3AccessKey string `envconfig:"document_s3_access_key"`
+	S3SecretKey string `vaultconfig:"secret/s3/bookings"`
+	S3PathStyle bool   `envconfig:"document_s3_path_style" default:"true"`
+}
+
+// New creates the blob store selected by the configuration
+func New(conf Config) (BlobStore, error) {
+	switch conf.Backend {
+	case "local":
+		return NewLocal(conf.Dir)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  conf.S3Endpoint,
+			Region:    conf.S3Region,
+			Bucket:    conf.S3Bucket,
+			AccessKey: conf.S3AccessKey,
+			SecretKey: conf.S3SecretKey,
+			PathStyle: conf.S3PathStyle,
+		})
+	}
+	return nil, fmt.Errorf("unknown blob store backend %q", conf.Backend)
+}
@@ -0,0 +1,267 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Actions taken on the booking requests still awaiting the provider after the approval SLA
+const (
+	BreachEscalate = "escalate"
+	BreachReject   = "reject"
+)
+
+// approvalRejectedInfo is the state information of the bookings rejected by RejectOverdueApprovals
+const approvalRejectedInfo = "not answered within the approval SLA"
+
+// awaitingStates are the states in which a booking awaits the answer of the provider
+var awaitingStates = []string{"pending", "pending_resp"}
+
+func isAwaitingState(state string) bool {
+	for _, s := range awaitingStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+const approvalSLAColumns = `id, provider_id, hotel_id, response_minutes, breach_action, escalation_email,
+	created_at, updated_at`
+
+func validateApprovalSLA(body *ApprovalSLAPost) error {
+	if body.HotelID == uuid.Nil {
+		return fmt.Errorf("hotel_id is mandatory")
+	}
+	if body.ResponseMinutes <= 0 {
+		return fmt.Errorf("response_minutes must be positive")
+	}
+	if body.BreachAction != BreachEscalate && body.BreachAction != BreachReject {
+		return fmt.Errorf("breach_action must be %q or %q", BreachEscalate, BreachReject)
+	}
+	if body.EscalationEmail != nil {
+		if _, err := mail.ParseAddress(*body.EscalationEmail); err != nil {
+			return fmt.Errorf("invalid escalation_email %q", *body.EscalationEmail)
+		}
+	}
+	return nil
+}
+
+func approvalSLAError(err error) (int, error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		return http.StatusConflict, fmt.Errorf("the hotel already has an approval SLA")
+	}
+	return http.StatusInternalServerError, err
+}
+
+// CreateApprovalSLA creates the approval SLA of a hotel of the provider
+func CreateApprovalSLA(providerID uuid.UUID, body *ApprovalSLAPost) (*ApprovalSLA, int, error) {
+	if err := validateApprovalSLA(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if _, state, err := providerHotel(providerID, nil, &body.HotelID); err != nil {
+		return nil, state, err
+	}
+	now := time.Now().UTC()
+	s := ApprovalSLA{
+		ID:              uuid.NewV4(),
+		ProviderID:      providerID,
+		HotelID:         body.HotelID,
+		ResponseMinutes: body.ResponseMinutes,
+		BreachAction:    body.BreachAction,
+		EscalationEmail: body.EscalationEmail,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	_, err := database.NamedExec(`
+		INSERT INTO approval_slas (`+approvalSLAColumns+`)
+		VALUES (:id, :provider_id, :hotel_id, :response_minutes, :breach_action, :escalation_email,
+			:created_at, :updated_at)`, &s)
+	if err != nil {
+		state, err := approvalSLAError(err)
+		return nil, state, err
+	}
+	return &s, http.StatusOK, nil
+}
+
+// UpdateApprovalSLA replaces an approval SLA of the provider, the deadlines of the awaiting
+// booking requests follow it
+func UpdateApprovalSLA(providerID, id uuid.UUID, body *ApprovalSLAPost) (*ApprovalSLA, int, error) {
+	if err := validateApprovalSLA(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if _, state, err := providerHotel(providerID, nil, &body.HotelID); err != nil {
+		return nil, state, err
+	}
+	var s ApprovalSLA
+	err := database.Get(&s, `
+		UPDATE approval_slas SET hotel_id = $3, response_minutes = $4, breach_action = $5, escalation_email = $6,
+			updated_at = $7
+		WHERE id = $1 AND provider_id = $2
+		RETURNING `+approvalSLAColumns,
+		id, providerID, body.HotelID, body.ResponseMinutes, body.BreachAction, body.EscalationEmail, time.Now().UTC())
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("approval SLA %s not found", id)
+	}
+	if err != nil {
+		state, err := approvalSLAError(err)
+		return nil, state, err
+	}
+	return &s, http.StatusOK, nil
+}
+
+// GetApprovalSLAs lists the approval SLAs of the provider
+func GetApprovalSLAs(providerID uuid.UUID) ([]ApprovalSLA, error) {
+	slas := []ApprovalSLA{}
+	err := database.Select(&slas, `SELECT `+approvalSLAColumns+` FROM approval_slas
+		WHERE provider_id = $1 ORDER BY hotel_id`, providerID)
+	return slas, err
+}
+
+// DeleteApprovalSLA removes an approval SLA of the provider
+func DeleteApprovalSLA(providerID, id uuid.UUID) (int, error) {
+	res, err := database.Exec(`DELETE FROM approval_slas WHERE id = $1 AND provider_id = $2`, id, providerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("approval SLA %s not found", id)
+	}
+	return http.StatusNoContent, nil
+}
+
+// GetApprovalEscalationEmail returns the escalation email of the approval SLA of the room, an
+// empty one when there is none
+func GetApprovalEscalationEmail(roomID uuid.UUID) (string, error) {
+	var email string
+	err := database.Get(&email, `
+		SELECT COALESCE(s.escalation_email, '') FROM approval_slas s JOIN rooms r ON r.hotel_id = s.hotel_id
+		WHERE r.id = $1 AND s.provider_id = r.provider`, roomID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return email, err
+}
+
+// GetApprovalQueue returns a page of the booking requests awaiting the answer of the provider,
+// the oldest first, and their total count
+func GetApprovalQueue(providerID uuid.UUID, hotelID *uuid.UUID, pageNumber, perPage int) ([]ApprovalQueueItem, int, error) {
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	where := `r.provider = $1 AND b.state::text = ANY($2)`
+	args := []interface{}{providerID, pq.Array(awaitingStates)}
+	if hotelID != nil {
+		args = append(args, *hotelID)
+		where += fmt.Sprintf(` AND r.hotel_id = $%d`, len(args))
+	}
+	var total int
+	err := database.Get(&total, `SELECT count(*) FROM bookings b JOIN rooms r ON r.id = b.room_id WHERE `+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	items := []ApprovalQueueItem{}
+	args = append(args, perPage, (pageNumber-1)*perPage)
+	err = database.Select(&items, fmt.Sprintf(`
+		SELECT `+prefixColumns("b", bookingColumns)+`,
+			b.awaiting_since + s.response_minutes * interval '1 minute' AS deadline
+		FROM bookings b JOIN rooms r ON r.id = b.room_id
+			LEFT JOIN approval_slas s ON s.hotel_id = r.hotel_id AND s.provider_id = r.provider
+		WHERE `+where+`
+		ORDER BY b.awaiting_since, b.id LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	for i := range items {
+		items[i].Overdue = items[i].Deadline != nil && !items[i].Deadline.After(now)
+	}
+	return items, total, nil
+}
+
+// overdueApprovals selects up to $3 bookings awaiting the provider, in the states $1, past the
+// deadline at $2 of an approval SLA with the breach action $4
+const overdueApprovals = `
+	SELECT b.id, b.state FROM bookings b
+		JOIN rooms r ON r.id = b.room_id
+		JOIN approval_slas s ON s.hotel_id = r.hotel_id AND s.provider_id = r.provider
+	WHERE b.state::text = ANY($1) AND s.breach_action = $4
+		AND b.awaiting_since + s.response_minutes * interval '1 minute' <= $2`
+
+// RejectOverdueApprovals rejects up to limit booking requests not answered within the approval
+// SLA of their hotel, it returns the number of rejected bookings, 0 when another instance is
+// running the transition
+func RejectOverdueApprovals(limit int) (int, error) {
+	return transitionBookings(rejectOverdueLock, events.BookingUpdated, `
+		WITH due AS (`+overdueApprovals+`
+			ORDER BY b.awaiting_since
+			LIMIT $3
+			FOR UPDATE OF b SKIP LOCKED)
+		UPDATE bookings b SET state = 'rejected', state_information = $5
+		FROM due WHERE b.id = due.id
+		RETURNING `+prefixColumns("b", bookingColumns)+`, due.state AS previous_state`,
+		pq.Array(awaitingStates), time.Now().UTC(), limit, BreachReject, approvalRejectedInfo)
+}
+
+// EscalateOverdueApprovals escalates once up to limit booking requests not answered within the
+// approval SLA of their hotel, it returns the number of escalated bookings, 0 when another
+// instance is running the transition
+func EscalateOverdueApprovals(limit int) (int, error) {
+	return transitionBookings(escalateOverdueLock, events.BookingEscalated, `
+		WITH due AS (`+overdueApprovals+` AND b.escalated_at IS NULL
+			ORDER BY b.awaiting_since
+			LIMIT $3
+			FOR UPDATE OF b SKIP LOCKED)
+		UPDATE bookings b SET escalated_at = $2
+		FROM due WHERE b.id = due.id
+		RETURNING `+prefixColumns("b", bookingColumns)+`, due.state AS previous_state`,
+		pq.Array(awaitingStates), time.Now().UTC(), limit, BreachEscalate)
+}
+
+// GetApprovalMetrics returns per hotel the latencies of the answers of the provider given in
+// the [from, to) period, computed from the events log
+func GetApprovalMetrics(providerID uuid.UUID, from, to time.Time) ([]ApprovalMetrics, int, error) {
+	if !from.Before(to) {
+		return nil, http.StatusBadRequest, fmt.Errorf("from must be before to")
+	}
+	metrics := []ApprovalMetrics{}
+	err := database.Select(&metrics, `
+		WITH answers AS (
+			SELECT a.room_id, a.state, a.booking->>'state_information' AS state_information,
+				extract(epoch FROM a.created_at - (
+					SELECT max(e.created_at) FROM booking_events e
+					WHERE e.booking_id = a.booking_id AND e.created_at <= a.created_at AND e.state = ANY($2)
+						AND (e.previous_state IS NULL OR NOT (e.previous_state = ANY($2))))) AS latency
+			FROM booking_events a
+			WHERE a.previous_state = ANY($2) AND a.state IN ('booked', 'rejected')
+				AND a.created_at >= $3 AND a.created_at < $4)
+		SELECT r.hotel_id, count(*) AS answered,
+			count(*) FILTER (WHERE a.state = 'booked') AS approved,
+			count(*) FILTER (WHERE a.state = 'rejected') AS rejected,
+			count(*) FILTER (WHERE a.state_information = $5) AS auto_rejected,
+			count(*) FILTER (WHERE a.latency > s.response_minutes * 60) AS breached,
+			avg(a.latency) AS average_seconds,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY a.latency) AS median_seconds,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY a.latency) AS p90_seconds
+		FROM answers a JOIN rooms r ON r.id = a.room_id
+			LEFT JOIN approval_slas s ON s.hotel_id = r.hotel_id AND s.provider_id = r.provider
+		WHERE r.provider = $1 AND a.latency IS NOT NULL
+		GROUP BY r.hotel_id
+		ORDER BY r.hotel_id`,
+		providerID, pq.Array(awaitingStates), from, to, approvalRejectedInfo)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return metrics, http.StatusOK, nil
+}
@@ -0,0 +1,166 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// MaxAvailabilityPeriod is the longest period the availability of a room is reported for
+const MaxAvailabilityPeriod = 31 * 24 * time.Hour
+
+// occupancy is a booking or an unexpired hold taking places of a room
+type occupancy struct {
+	ID        uuid.UUID `db:"id"`
+	StartTime time.Time `db:"start_time"`
+	EndTime   time.Time `db:"end_time"`
+	PartySize int16     `db:"party_size"`
+}
+
+// getRoomCapacity returns whether the room is shared and the number of persons it can take, a
+// room which isn't shared is taken as a whole by a single booking whatever its party size and
+// its capacity is that booking
+func getRoomCapacity(q sqlx.Queryer, roomID uuid.UUID) (bool, int, error) {
+	var room struct {
+		IsShared       bool   `db:"is_shared"`
+		SharedNrPerson *int16 `db:"shared_nr_person"`
+	}
+	if err := sqlx.Get(q, &room, `SELECT is_shared, shared_nr_person FROM rooms WHERE id = $1`, roomID); err != nil {
+		return false, 0, err
+	}
+	if !room.IsShared {
+		return false, 1, nil
+	}
+	if room.SharedNrPerson == nil || *room.SharedNrPerson <= 0 {
+		return true, 1, nil
+	}
+	return true, int(*room.SharedNrPerson), nil
+}
+
+// occupyingBookings returns the bookings and unexpired holds of the room overlapping the
+// [start, end) period, except the ones listed in exclude
+func occupyingBookings(q sqlx.Queryer, roomID uuid.UUID, start, end time.Time, exclude ...uuid.UUID) ([]occupancy, error) {
+	var occupying []occupancy
+	err := sqlx.Select(q, &occupying, `
+		SELECT id, start_time, end_time, party_size FROM bookings
+		WHERE room_id = $1 AND start_time < $3 AND end_time > $2
+			AND (state::text = ANY($4) OR (state = 'draft' AND expires_at > $6))
+			AND NOT (id::text = ANY($5))
+		ORDER BY start_time`,
+		roomID, start, end, pq.Array(blockingStates), pq.Array(uuidStrings(exclude)), time.Now().UTC())
+	return occupying, err
+}
+
+// peakOccupancy returns the highest number of persons in the room during the [start, end) period,
+// the occupancy only grows when a booking starts
+func peakOccupancy(occupying []occupancy, start, end time.Time) int {
+	peak := 0
+	for _, p := range occupying {
+		at := p.StartTime
+		if at.Before(start) {
+			at = start
+		}
+		if n := occupiedAt(occupying, at); n > peak {
+			peak = n
+		}
+	}
+	return peak
+}
+
+func occupiedAt(occupying []occupancy, at time.Time) int {
+	n := 0
+	for _, o := range occupying {
+		if !o.StartTime.After(at) && o.EndTime.After(at) {
+			n += int(o.PartySize)
+		}
+	}
+	return n
+}
+
+// blockedAt returns the kind of the block closing the room at the given time
+func blockedAt(blocks []blockPeriod, at time.Time) string {
+	for _, b := range blocks {
+		if !b.StartTime.After(at) && b.EndTime.After(at) {
+			return b.Kind
+		}
+	}
+	return ""
+}
+
+// GetRoomAvailability splits the [from, to) period in slots of constant occupancy of the room
+func GetRoomAvailability(roomID uuid.UUID, from, to time.Time) ([]RoomAvailabilitySlot, int, error) {
+	if !from.Before(to) {
+		return nil, http.StatusBadRequest, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > MaxAvailabilityPeriod {
+		return nil, http.StatusBadRequest, fmt.Errorf("the availability is reported for %s at most", MaxAvailabilityPeriod)
+	}
+	shared, capacity, err := getRoomCapacity(database, roomID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("room %s not found", roomID)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	occupying, err := occupyingBookings(database, roomID, from, to)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	blocks, err := roomBlockPeriods(database, roomID, from, to)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	bounds := []time.Time{from, to}
+	addBounds := func(start, end time.Time) {
+		if start.After(from) {
+			bounds = append(bounds, start)
+		}
+		if end.Before(to) {
+			bounds = append(bounds, end)
+		}
+	}
+	for _, o := range occupying {
+		addBounds(o.StartTime, o.EndTime)
+	}
+	for _, b := range blocks {
+		addBounds(b.StartTime, b.EndTime)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+
+	slots := []RoomAvailabilitySlot{}
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		if !start.Before(end) {
+			continue
+		}
+		occupied := occupiedAt(occupying, start)
+		if !shared && occupied > 0 {
+			occupied = capacity
+		}
+		remaining := capacity - occupied
+		blocked := blockedAt(blocks, start)
+		if remaining < 0 || blocked != "" {
+			remaining = 0
+		}
+		if n := len(slots); n > 0 && slots[n-1].Occupied == occupied && slots[n-1].Blocked == blocked {
+			slots[n-1].EndTime = end
+			continue
+		}
+		slots = append(slots, RoomAvailabilitySlot{
+			StartTime: start,
+			EndTime:   end,
+			Capacity:  capacity,
+			Occupied:  occupied,
+			Remaining: remaining,
+			Blocked:   blocked,
+		})
+	}
+	return slots, http.StatusOK, nil
+}
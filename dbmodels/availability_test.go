@@ -0,0 +1,32 @@
+package dbmodels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeakOccupancy(t *testing.T) {
+	base := time.Date(2019, 1, 10, 0, 0, 0, 0, time.UTC)
+	h := func(n int) time.Time { return base.Add(time.Duration(n) * time.Hour) }
+	o := func(start, end int, party int16) occupancy {
+		return occupancy{StartTime: h(start), EndTime: h(end), PartySize: party}
+	}
+	tests := []struct {
+		name       string
+		occupying  []occupancy
+		start, end int
+		peak       int
+	}{
+		{"empty", nil, 0, 6, 0},
+		{"single", []occupancy{o(1, 3, 2)}, 0, 6, 2},
+		{"overlapping", []occupancy{o(0, 4, 2), o(1, 3, 1), o(2, 6, 3)}, 0, 6, 6},
+		{"started before the period", []occupancy{o(0, 4, 2), o(1, 3, 1), o(2, 6, 3)}, 3, 6, 5},
+		{"back to back", []occupancy{o(0, 2, 2), o(2, 4, 2)}, 0, 4, 2},
+		{"disjoint", []occupancy{o(0, 1, 4), o(2, 3, 1), o(2, 5, 1)}, 0, 6, 4},
+	}
+	for _, tt := range tests {
+		if got := peakOccupancy(tt.occupying, h(tt.start), h(tt.end)); got != tt.peak {
+			t.Errorf("%s: peak = %d, want %d", tt.name, got, tt.peak)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Kinds of the room blocks
+const (
+	BlockBlackout    = "blackout"
+	BlockMaintenance = "maintenance"
+)
+
+const roomBlockColumns = `id, provider_id, hotel_id, room_id, kind, reason, start_time, end_time, rrule,
+	created_at, updated_at`
+
+// blockPeriod is an occurrence of a room block
+type blockPeriod struct {
+	BlockID   uuid.UUID
+	Kind      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// zonedBlock is a room block with the time zone of its hotel, the recurring blocks keep their
+// local time of the day across DST transitions
+type zonedBlock struct {
+	RoomBlock
+	TimeZone string `db:"time_zone"`
+}
+
+func validateRoomBlock(body *RoomBlockPost) error {
+	if body.RoomID == nil && body.HotelID == nil {
+		return fmt.Errorf("either room_id or hotel_id is mandatory")
+	}
+	if body.Kind != BlockBlackout && body.Kind != BlockMaintenance {
+		return fmt.Errorf("kind must be %q or %q", BlockBlackout, BlockMaintenance)
+	}
+	if body.StartTime.IsZero() || body.EndTime.IsZero() {
+		return fmt.Errorf("start_time and end_time are mandatory")
+	}
+	if !body.StartTime.Before(body.EndTime) {
+		return fmt.Errorf("start_time must be before end_time")
+	}
+	if body.RRule != nil {
+		rule, err := ParseRRule(*body.RRule)
+		if err != nil {
+			return err
+		}
+		if _, err = rule.Expand(body.StartTime, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// occurrences returns the periods of the block
+func (b *zonedBlock) occurrences() ([]blockPeriod, error) {
+	starts := []time.Time{b.StartTime}
+	if b.RRule != nil {
+		loc, err := time.LoadLocation(b.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		rule, err := ParseRRule(*b.RRule)
+		if err != nil {
+			return nil, err
+		}
+		if starts, err = rule.Expand(b.StartTime.In(loc), nil); err != nil {
+			return nil, err
+		}
+	}
+	duration := b.EndTime.Sub(b.StartTime)
+	periods := make([]blockPeriod, 0, len(starts))
+	for _, start := range starts {
+		periods = append(periods, blockPeriod{
+			BlockID:   b.ID,
+			Kind:      b.Kind,
+			StartTime: start.UTC(),
+			EndTime:   start.Add(duration).UTC(),
+		})
+	}
+	return periods, nil
+}
+
+// roomBlockPeriods returns the periods of the blocks of the room, and of its hotel, overlapping
+// the [start, end) period
+func roomBlockPeriods(q sqlx.Queryer, roomID uuid.UUID, start, end time.Time) ([]blockPeriod, error) {
+	var blocks []zonedBlock
+	err := sqlx.Select(q, &blocks, `
+		SELECT `+prefixColumns("b", roomBlockColumns)+`, h.time_zone
+		FROM room_blocks b JOIN rooms r ON r.hotel_id = b.hotel_id JOIN hotels h ON h.id = b.hotel_id
+		WHERE r.id = $1 AND (b.room_id = r.id OR (b.room_id IS NULL AND b.provider_id = r.provider))
+			AND b.start_time < $3 AND (b.rrule IS NOT NULL OR b.end_time > $2)`,
+		roomID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	periods := []blockPeriod{}
+	for i := range blocks {
+		occurrences, err := blocks[i].occurrences()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range occurrences {
+			if p.StartTime.Before(end) && p.EndTime.After(start) {
+				periods = append(periods, p)
+			}
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].StartTime.Before(periods[j].StartTime) })
+	return periods, nil
+}
+
+// checkBlocks fails with 409 when a block closes the room in the [start, end) period
+func checkBlocks(q sqlx.Queryer, roomID uuid.UUID, start, end time.Time) (int, error) {
+	periods, err := roomBlockPeriods(q, roomID, start, end)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(periods) > 0 {
+		p := periods[0]
+		return http.StatusConflict, fmt.Errorf("room %s is closed for %s between %s and %s by block %s",
+			roomID, p.Kind, p.StartTime.Format(time.RFC3339), p.EndTime.Format(time.RFC3339), p.BlockID)
+	}
+	return http.StatusOK, nil
+}
+
+// checkBlockedBookings locks the rooms closed by the block and fails with 409 when one of
+// their bookings overlaps it, they have to be cancelled or moved first
+func checkBlockedBookings(tx *txn, b *RoomBlock) (int, error) {
+	zoned := zonedBlock{RoomBlock: *b}
+	err := tx.Get(&zoned.TimeZone, `SELECT time_zone FROM hotels WHERE id = $1`, b.HotelID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	periods, err := zoned.occurrences()
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(periods) == 0 {
+		return http.StatusOK, nil
+	}
+
+	var roomIDs []uuid.UUID
+	if b.RoomID != nil {
+		roomIDs = []uuid.UUID{*b.RoomID}
+	} else {
+		err = tx.Select(&roomIDs, `SELECT id FROM rooms WHERE hotel_id = $1 AND provider = $2 ORDER BY id`,
+			b.HotelID, b.ProviderID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	first, last := periods[0].StartTime, periods[len(periods)-1].EndTime
+	for _, roomID := range roomIDs {
+		if state, err := lockRoom(tx, roomID); err != nil {
+			return state, err
+		}
+		occupying, err := occupyingBookings(tx, roomID, first, last)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		for _, o := range occupying {
+			for _, p := range periods {
+				if o.StartTime.Before(p.EndTime) && o.EndTime.After(p.StartTime) {
+					return http.StatusConflict, fmt.Errorf("booking request %s of room %s overlaps the block between %s and %s",
+						o.ID, roomID, p.StartTime.Format(time.RFC3339), p.EndTime.Format(time.RFC3339))
+				}
+			}
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// CreateRoomBlock closes a room, or the rooms of the provider in a hotel, for bookings
+func CreateRoomBlock(providerID uuid.UUID, body *RoomBlockPost) (*RoomBlock, int, error) {
+	if err := validateRoomBlock(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	hotelID, state, err := providerHotel(providerID, body.RoomID, body.HotelID)
+	if err != nil {
+		return nil, state, err
+	}
+	now := time.Now().UTC()
+	b := RoomBlock{
+		ID:         uuid.NewV4(),
+		ProviderID: providerID,
+		HotelID:    hotelID,
+		RoomID:     body.RoomID,
+		Kind:       body.Kind,
+		Reason:     body.Reason,
+		StartTime:  body.StartTime,
+		EndTime:    body.EndTime,
+		RRule:      body.RRule,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	state, err = inTx(func(tx *txn) (int, error) {
+		if state, err := checkBlockedBookings(tx, &b); err != nil {
+			return state, err
+		}
+		_, err := tx.NamedExec(`
+			INSERT INTO room_blocks (`+roomBlockColumns+`)
+			VALUES (:id, :provider_id, :hotel_id, :room_id, :kind, :reason, :start_time, :end_time, :rrule,
+				:created_at, :updated_at)`, &b)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	return &b, http.StatusOK, nil
+}
+
+// UpdateRoomBlock replaces a room block of the provider
+func UpdateRoomBlock(providerID, id uuid.UUID, body *RoomBlockPost) (*RoomBlock, int, error) {
+	if err := validateRoomBlock(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	hotelID, state, err := providerHotel(providerID, body.RoomID, body.HotelID)
+	if err != nil {
+		return nil, state, err
+	}
+	var b RoomBlock
+	state, err = inTx(func(tx *txn) (int, error) {
+		err := tx.Get(&b, `
+			UPDATE room_blocks SET hotel_id = $3, room_id = $4, kind = $5, reason = $6, start_time = $7,
+				end_time = $8, rrule = $9, updated_at = $10
+			WHERE id = $1 AND provider_id = $2
+			RETURNING `+roomBlockColumns,
+			id, providerID, hotelID, body.RoomID, body.Kind, body.Reason, body.StartTime, body.EndTime, body.RRule,
+			time.Now().UTC())
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, fmt.Errorf("room block %s not found", id)
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return checkBlockedBookings(tx, &b)
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	return &b, http.StatusOK, nil
+}
+
+// GetRoomBlocks lists the room blocks of the provider
+func GetRoomBlocks(providerID uuid.UUID) ([]RoomBlock, error) {
+	blocks := []RoomBlock{}
+	err := database.Select(&blocks, `SELECT `+roomBlockColumns+` FROM room_blocks
+		WHERE provider_id = $1 ORDER BY hotel_id, start_time`, providerID)
+	return blocks, err
+}
+
+// DeleteRoomBlock removes a room block of the provider, its room is open for bookings again
+func DeleteRoomBlock(providerID, id uuid.UUID) (int, error) {
+	res, err := database.Exec(`DELETE FROM room_blocks WHERE id = $1 AND provider_id = $2`, id, providerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("room block %s not found", id)
+	}
+	return http.StatusNoContent, nil
+}
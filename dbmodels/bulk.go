@@ -0,0 +1,149 @@
+package dbmodels
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Bulk operation types
+const (
+	BulkCreate = "create"
+	BulkPatch  = "patch"
+	BulkDelete = "delete"
+)
+
+// errBulkOperation rolls back the transaction of a failed operation, its result tells why
+var errBulkOperation = errors.New("bulk operation failed")
+
+// MaxBulkOperations is the maximum number of operations accepted in one bulk request
+const MaxBulkOperations = 100
+
+// RunBulk executes the operations of a bulk request. Every operation runs in its own
+// transaction unless atomic is set, then all of them run in a single transaction and
+// nothing is applied when one of them fails.
+func RunBulk(ops []BulkOperation, atomic bool, customerID, requestorID uuid.UUID) (*BulkResponse, error) {
+	resp := &BulkResponse{Atomic: atomic, Results: make([]BulkResult, len(ops))}
+
+	if !atomic {
+		for i := range ops {
+			state, err := inTx(func(tx *txn) (int, error) {
+				resp.Results[i] = runBulkOperation(tx, i, ops[i], customerID, requestorID)
+				if resp.Results[i].Status >= http.StatusBadRequest {
+					return resp.Results[i].Status, errBulkOperation
+				}
+				return resp.Results[i].Status, nil
+			})
+			// the transaction may fail to begin or to commit after the operation succeeded
+			if err != nil && err != errBulkOperation {
+				msg := err.Error()
+				resp.Results[i] = BulkResult{Index: i, Op: ops[i].Op, ID: ops[i].ID, Status: state, Message: &msg}
+			}
+		}
+		resp.count()
+		return resp, nil
+	}
+
+	tx, err := begin()
+	if err != nil {
+		return nil, err
+	}
+	failed := -1
+	for i := range ops {
+		resp.Results[i] = runBulkOperation(tx, i, ops[i], customerID, requestorID)
+		if resp.Results[i].Status >= http.StatusBadRequest {
+			failed = i
+			break
+		}
+	}
+	if failed < 0 {
+		if err = tx.commit(); err != nil {
+			return nil, err
+		}
+		resp.count()
+		return resp, nil
+	}
+
+	tx.Rollback()
+	msg := fmt.Sprintf("not applied, operation %d failed", failed)
+	for i := range ops {
+		if i == failed {
+			continue
+		}
+		resp.Results[i] = BulkResult{
+			Index:   i,
+			Op:      ops[i].Op,
+			ID:      ops[i].ID,
+			Status:  http.StatusFailedDependency,
+			Message: &msg,
+		}
+	}
+	resp.count()
+	return resp, nil
+}
+
+func (r *BulkResponse) count() {
+	r.Succeeded, r.Failed = 0, 0
+	for _, res := range r.Results {
+		if res.Status >= http.StatusBadRequest {
+			r.Failed++
+		} else {
+			r.Succeeded++
+		}
+	}
+}
+
+func runBulkOperation(tx *txn, index int, op BulkOperation, customerID, requestorID uuid.UUID) BulkResult {
+	res := BulkResult{Index: index, Op: op.Op, ID: op.ID}
+	fail := func(status int, err error) BulkResult {
+		if strings.Contains(err.Error(), "duplicate key") {
+			status = http.StatusConflict
+		}
+		msg := err.Error()
+		res.Status = status
+		res.Message = &msg
+		return res
+	}
+
+	switch op.Op {
+	case BulkCreate:
+		if op.Create == nil {
+			return fail(http.StatusBadRequest, fmt.Errorf("create body is mandatory"))
+		}
+		body := *op.Create
+		body.CustomerID = customerID
+		body.RequestorID = requestorID
+		b, state, err := postBooking(tx, &body)
+		if err != nil {
+			return fail(state, err)
+		}
+		res.ID = &b.ID
+		res.Booking = b
+		res.Status = http.StatusCreated
+	case BulkPatch:
+		if op.ID == nil || op.Patch == nil {
+			return fail(http.StatusBadRequest, fmt.Errorf("id and patch body are mandatory"))
+		}
+		b, state, err := patchBooking(tx, op.Patch, *op.ID)
+		if err != nil {
+			return fail(state, err)
+		}
+		res.Booking = b
+		res.Status = http.StatusOK
+	case BulkDelete:
+		if op.ID == nil {
+			return fail(http.StatusBadRequest, fmt.Errorf("id is mandatory"))
+		}
+		state, err := deleteBooking(tx, nil, *op.ID)
+		if err != nil {
+			return fail(state, err)
+		}
+		res.Status = http.StatusNoContent
+	default:
+		return fail(http.StatusBadRequest, fmt.Errorf("unknown operation %q", op.Op))
+	}
+	return res
+}
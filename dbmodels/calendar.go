@@ -0,0 +1,84 @@
+package dbmodels
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// calendarFeedPast is how far back the calendar feed lists bookings
+const calendarFeedPast = 90 * 24 * time.Hour
+
+// CreateCalendarToken creates a new calendar feed token for the customer
+func CreateCalendarToken(customerID uuid.UUID) (*CalendarToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	t := CalendarToken{
+		Token:      hex.EncodeToString(buf),
+		CustomerID: customerID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	_, err := database.NamedExec(`
+		INSERT INTO calendar_tokens (token, customer_id, created_at)
+		VALUES (:token, :customer_id, :created_at)`, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetCalendarTokens lists the calendar feed tokens of the customer
+func GetCalendarTokens(customerID uuid.UUID) ([]CalendarToken, error) {
+	tokens := []CalendarToken{}
+	err := database.Select(&tokens, `
+		SELECT token, customer_id, created_at, revoked_at FROM calendar_tokens
+		WHERE customer_id = $1 ORDER BY created_at`, customerID)
+	return tokens, err
+}
+
+// RevokeCalendarToken revokes a calendar feed token of the customer
+func RevokeCalendarToken(customerID uuid.UUID, token string) (int, error) {
+	res, err := database.Exec(`
+		UPDATE calendar_tokens SET revoked_at = now()
+		WHERE token = $1 AND customer_id = $2 AND revoked_at IS NULL`, token, customerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("calendar token not found")
+	}
+	return http.StatusNoContent, nil
+}
+
+// GetCalendarFeed returns the bookings of the customer owning the token
+func GetCalendarFeed(token string) ([]Booking, int, error) {
+	var customerID uuid.UUID
+	err := database.Get(&customerID, `
+		SELECT customer_id FROM calendar_tokens WHERE token = $1 AND revoked_at IS NULL`, token)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("calendar not found")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	bookings := []Booking{}
+	err = database.Select(&bookings, `
+		SELECT `+bookingColumns+` FROM bookings
+		WHERE customer_id = $1 AND end_time > $2 AND state <> 'draft'
+		ORDER BY start_time`, customerID, time.Now().UTC().Add(-calendarFeedPast))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err = EmbedResources(bookings, []string{EmbedRoom, EmbedHotel}); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return bookings, http.StatusOK, nil
+}
@@ -0,0 +1,208 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+const cancellationPolicyColumns = `id, provider_id, hotel_id, room_id, name, free_until_hours, fee_percent,
+	created_at, updated_at`
+
+func validateCancellationPolicy(body *CancellationPolicyPost) error {
+	if body.Name == "" {
+		return fmt.Errorf("name is mandatory")
+	}
+	if body.RoomID == nil && body.HotelID == nil {
+		return fmt.Errorf("either room_id or hotel_id is mandatory")
+	}
+	if body.FreeUntilHours < 0 {
+		return fmt.Errorf("free_until_hours can't be negative")
+	}
+	if body.FeePercent < 0 || body.FeePercent > 100 {
+		return fmt.Errorf("fee_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+func cancellationPolicyError(err error) (int, error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		return http.StatusConflict, fmt.Errorf("the room or hotel already has a cancellation policy")
+	}
+	return http.StatusInternalServerError, err
+}
+
+// CreateCancellationPolicy creates a cancellation policy of the provider, a policy of a room
+// takes precedence over the one of its hotel
+func CreateCancellationPolicy(providerID uuid.UUID, body *CancellationPolicyPost) (*CancellationPolicy, int, error) {
+	if err := validateCancellationPolicy(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	hotelID, state, err := providerHotel(providerID, body.RoomID, body.HotelID)
+	if err != nil {
+		return nil, state, err
+	}
+	now := time.Now().UTC()
+	p := CancellationPolicy{
+		ID:             uuid.NewV4(),
+		ProviderID:     providerID,
+		HotelID:        hotelID,
+		RoomID:         body.RoomID,
+		Name:           body.Name,
+		FreeUntilHours: body.FreeUntilHours,
+		FeePercent:     body.FeePercent,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	_, err = database.NamedExec(`
+		INSERT INTO cancellation_policies (`+cancellationPolicyColumns+`)
+		VALUES (:id, :provider_id, :hotel_id, :room_id, :name, :free_until_hours, :fee_percent,
+			:created_at, :updated_at)`, &p)
+	if err != nil {
+		state, err := cancellationPolicyError(err)
+		return nil, state, err
+	}
+	return &p, http.StatusOK, nil
+}
+
+// UpdateCancellationPolicy replaces a cancellation policy of the provider, the fees of the
+// cancelled bookings don't change
+func UpdateCancellationPolicy(providerID, id uuid.UUID, body *CancellationPolicyPost) (*CancellationPolicy, int, error) {
+	if err := validateCancellationPolicy(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	hotelID, state, err := providerHotel(providerID, body.RoomID, body.HotelID)
+	if err != nil {
+		return nil, state, err
+	}
+	var p CancellationPolicy
+	err = database.Get(&p, `
+		UPDATE cancellation_policies SET hotel_id = $3, room_id = $4, name = $5, free_until_hours = $6,
+			fee_percent = $7, updated_at = $8
+		WHERE id = $1 AND provider_id = $2
+		RETURNING `+cancellationPolicyColumns,
+		id, providerID, hotelID, body.RoomID, body.Name, body.FreeUntilHours, body.FeePercent, time.Now().UTC())
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("cancellation policy %s not found", id)
+	}
+	if err != nil {
+		state, err := cancellationPolicyError(err)
+		return nil, state, err
+	}
+	return &p, http.StatusOK, nil
+}
+
+// GetCancellationPolicies lists the cancellation policies of the provider
+func GetCancellationPolicies(providerID uuid.UUID) ([]CancellationPolicy, error) {
+	policies := []CancellationPolicy{}
+	err := database.Select(&policies, `SELECT `+cancellationPolicyColumns+` FROM cancellation_policies
+		WHERE provider_id = $1 ORDER BY hotel_id, name`, providerID)
+	return policies, err
+}
+
+// DeleteCancellationPolicy removes a cancellation policy of the provider
+func DeleteCancellationPolicy(providerID, id uuid.UUID) (int, error) {
+	res, err := database.Exec(`DELETE FROM cancellation_policies WHERE id = $1 AND provider_id = $2`, id, providerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("cancellation policy %s not found", id)
+	}
+	return http.StatusNoContent, nil
+}
+
+// GetCancellationPreview tells the customer what cancelling the booking now would cost
+func GetCancellationPreview(id, customerID uuid.UUID) (*CancellationPreview, int, error) {
+	var b Booking
+	err := database.Get(&b, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1 AND customer_id = $2`, id, customerID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("booking request %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if b.State == "cancelled" {
+		return nil, http.StatusConflict, fmt.Errorf("booking request %s is already cancelled", id)
+	}
+	preview, err := evaluateCancellation(database, &b, time.Now().UTC())
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if !isBlockingState(b.State) {
+		// only the bookings occupying their room are charged
+		preview.Fee = new(int64)
+	}
+	return preview, http.StatusOK, nil
+}
+
+// evaluateCancellation applies the cancellation policy of the room of the booking, or else the
+// one of its hotel, to a cancellation at the given time
+func evaluateCancellation(q sqlx.Queryer, b *Booking, at time.Time) (*CancellationPreview, error) {
+	preview := CancellationPreview{BookingID: b.ID, Currency: b.Currency, Fee: new(int64)}
+	var p CancellationPolicy
+	err := sqlx.Get(q, &p, `
+		SELECT `+prefixColumns("p", cancellationPolicyColumns)+`
+		FROM cancellation_policies p JOIN rooms r ON r.hotel_id = p.hotel_id
+		WHERE r.id = $1 AND (p.room_id = r.id OR (p.room_id IS NULL AND p.provider_id = r.provider))
+		ORDER BY p.room_id IS NULL
+		LIMIT 1`, b.RoomID)
+	if err == sql.ErrNoRows {
+		return &preview, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.apply(&preview, b, at)
+	return &preview, nil
+}
+
+// apply sets the policy and the fee of a cancellation of the booking at the given time, the
+// fee is unknown when the booking has no price
+func (p *CancellationPolicy) apply(preview *CancellationPreview, b *Booking, at time.Time) {
+	freeUntil := b.StartTime.Add(-time.Duration(p.FreeUntilHours) * time.Hour)
+	preview.PolicyID = &p.ID
+	preview.PolicyName = p.Name
+	preview.FreeUntil = &freeUntil
+	preview.FeePercent = p.FeePercent
+	if at.Before(freeUntil) {
+		return
+	}
+	if b.TotalPrice == nil {
+		preview.Fee = nil
+		return
+	}
+	fee := cancellationFee(*b.TotalPrice, p.FeePercent)
+	preview.Fee = &fee
+}
+
+// cancellationFee returns the percentage of the price rounded half up to the minor unit
+func cancellationFee(price int64, percent int) int64 {
+	return (price*int64(percent) + 50) / 100
+}
+
+// applyCancellation records the cancellation policy and the fee of the booking cancelled by the
+// customer, the provider cancellations and the ones of bookings which didn't occupy their room
+// are free
+func applyCancellation(tx *txn, b *Booking, previousState string, waive bool) (int, error) {
+	now := time.Now().UTC()
+	preview, err := evaluateCancellation(tx, b, now)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if waive || !isBlockingState(previousState) {
+		preview.Fee = new(int64)
+	}
+	err = tx.Get(b, `
+		UPDATE bookings SET cancellation_policy_id = $2, cancellation_fee = $3, cancelled_at = $4
+		WHERE id = $1 RETURNING `+bookingColumns, b.ID, preview.PolicyID, preview.Fee, now)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,57 @@
+package dbmodels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCancellationFee(t *testing.T) {
+	tests := []struct {
+		price   int64
+		percent int
+		fee     int64
+	}{
+		{10000, 0, 0},
+		{10000, 100, 10000},
+		{10000, 15, 1500},
+		{999, 50, 500},    // 499.5 rounds up
+		{998, 50, 499},    // 499 exactly
+		{1, 49, 0},        // 0.49 rounds down
+		{1, 50, 1},        // 0.5 rounds up
+		{333, 33, 110},    // 109.89
+		{12345, 10, 1235}, // 1234.5 rounds up
+	}
+	for _, tt := range tests {
+		if got := cancellationFee(tt.price, tt.percent); got != tt.fee {
+			t.Errorf("cancellationFee(%d, %d) = %d, want %d", tt.price, tt.percent, got, tt.fee)
+		}
+	}
+}
+
+func TestCancellationPolicyApply(t *testing.T) {
+	start := time.Date(2019, 2, 1, 14, 0, 0, 0, time.UTC)
+	price := int64(20001)
+	p := CancellationPolicy{Name: "48 hours", FreeUntilHours: 48, FeePercent: 25}
+	b := Booking{StartTime: start, TotalPrice: &price}
+	freeUntil := start.Add(-48 * time.Hour)
+
+	var preview CancellationPreview
+	preview.Fee = new(int64)
+	p.apply(&preview, &b, freeUntil.Add(-time.Second))
+	if preview.Fee == nil || *preview.Fee != 0 || preview.FreeUntil == nil || !preview.FreeUntil.Equal(freeUntil) {
+		t.Errorf("before the free cancellation deadline: %+v", preview)
+	}
+
+	preview = CancellationPreview{Fee: new(int64)}
+	p.apply(&preview, &b, freeUntil)
+	if preview.Fee == nil || *preview.Fee != 5000 {
+		t.Errorf("at the free cancellation deadline: fee %v, want 5000", preview.Fee)
+	}
+
+	b.TotalPrice = nil
+	preview = CancellationPreview{Fee: new(int64)}
+	p.apply(&preview, &b, start)
+	if preview.Fee != nil {
+		t.Errorf("without a price the fee is unknown, got %d", *preview.Fee)
+	}
+}
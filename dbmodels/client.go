@@ -1,8 +1,12 @@
 package dbmodels
 
 import (
+	"bookings/events"
+	"database/sql"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate"
 	"github.com/golang-migrate/migrate/database/postgres"
@@ -24,49 +28,383 @@ type Config struct {
 	Port     int    `envconfig:"postgres_port" default:"5432"`
 }
 
-// Connect to PostGres on Bespin returns db client
-func Connect(conf Config) (rawdb *sqlx.DB, err error) {
-	cn := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable",
+// database is the client used by the query functions of this package,
+// it is set by Connect
+var database *sqlx.DB
+
+func connString(conf Config) string {
+	return fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable",
 		conf.Host,
 		conf.Port,
 		conf.DBName,
 		conf.User,
 		conf.Password)
+}
 
-	rawdb, err = sqlx.Connect("postgres", cn)
+// Connect to PostGres on Bespin returns db client
+func Connect(conf Config) (rawdb *sqlx.DB, err error) {
+	rawdb, err = sqlx.Connect("postgres", connString(conf))
 	if err != nil {
 		log.Errorf("Failed to connect to postgres database: %s", err)
 		return
 	}
+	database = rawdb
 	return
 }
 
-// GetBooking ...
-func GetBooking(id uuid.UUID, actor string) (*Booking, int, error) {
+const bookingColumns = `id, room_id, customer_id, requestor_id, requested_at, start_time, end_time,
+	state, state_information, file_name, description, reference, series_id,
+	booking_request_email, booking_request_from_email, expires_at, party_size,
+	rate_plan_id, total_price, currency, cancellation_policy_id, cancellation_fee, cancelled_at,
+	awaiting_since, escalated_at`
 
-	return nil, http.StatusOK, nil
+// GetBooking returns the booking of the customer, or of a room of the provider, depending on the role
+func GetBooking(id uuid.UUID, role string, ownerID uuid.UUID) (*Booking, int, error) {
+	b, state, err := bookingAccess(database, id, role, ownerID, false)
+	if err != nil {
+		return nil, state, err
+	}
+	if err := localizeBooking(b); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return b, http.StatusOK, nil
 }
 
-// GetBookings ...
-func GetBookings(actor string) ([]Booking, int, error) {
-	return nil, 0, nil
+// GetBookings returns a page of the bookings matching the filter and their total count, the
+// filter scopes them to the caller
+func GetBookings(filter BookingFilter, pageNumber, perPage int) ([]Booking, int, error) {
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+	where, args := filter.where()
+	var total int
+	err := database.Get(&total, `SELECT count(*) FROM bookings b WHERE `+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	bookings := []Booking{}
+	args = append(args, perPage, (pageNumber-1)*perPage)
+	err = database.Select(&bookings, fmt.Sprintf(`SELECT `+bookingColumns+` FROM bookings b WHERE `+where+`
+		ORDER BY b.start_time, b.id LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := localize(bookings); err != nil {
+		return nil, 0, err
+	}
+	return bookings, total, nil
 }
 
 // PostBooking ...
 func PostBooking(body *BookingPost) (*Booking, int, error) {
-	return nil, http.StatusOK, nil
+	var res *Booking
+	state, err := inTx(func(tx *txn) (int, error) {
+		var state int
+		var err error
+		res, state, err = postBooking(tx, body)
+		return state, err
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	if err := localizeBooking(res); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return res, http.StatusOK, nil
+}
+
+// ValidateBookingPost checks the rules every new booking has to follow
+func ValidateBookingPost(body *BookingPost) error {
+	if body.RoomID == uuid.Nil {
+		return fmt.Errorf("room_id is mandatory")
+	}
+	if body.StartTime.IsZero() || body.EndTime.IsZero() {
+		return fmt.Errorf("start_time and end_time are mandatory")
+	}
+	if !body.StartTime.Before(body.EndTime) {
+		return fmt.Errorf("start_time must be before end_time")
+	}
+	if body.PartySize < 0 {
+		return fmt.Errorf("party_size must be positive")
+	}
+	return nil
+}
+
+func postBooking(tx *txn, body *BookingPost) (*Booking, int, error) {
+	if err := ValidateBookingPost(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if state, err := lockRoom(tx, body.RoomID); err != nil {
+		return nil, state, err
+	}
+	if body.RRule != nil {
+		return postBookingSeries(tx, body)
+	}
+	return insertBooking(tx, body, nil)
+}
+
+func insertBooking(tx *txn, body *BookingPost, seriesID *uuid.UUID) (*Booking, int, error) {
+	b := Booking{
+		ID:          uuid.NewV4(),
+		RoomID:      body.RoomID,
+		CustomerID:  body.CustomerID,
+		RequestorID: body.RequestorID,
+		RequestedAt: body.RequestedAt,
+		StartTime:   body.StartTime,
+		EndTime:     body.EndTime,
+		State:       body.State,
+		StateInfo:   body.StateInfo,
+		BucketName:  body.BucketName,
+		Description: body.Description,
+		Reference:   body.Reference,
+		SeriesID:    seriesID,
+		ExpiresAt:   body.ExpiresAt,
+		PartySize:   body.PartySize,
+
+		BookingRequestEmail:     body.BookingRequestEmail,
+		BookingRequestFromEmail: body.BookingRequestFromEmail,
+	}
+	if b.RequestedAt.IsZero() {
+		b.RequestedAt = time.Now().UTC()
+	}
+	if b.State == "" {
+		b.State = "pending"
+	}
+	if b.PartySize == 0 {
+		b.PartySize = 1
+	}
+	if isAwaitingState(b.State) {
+		now := time.Now().UTC()
+		b.AwaitingSince = &now
+	}
+	if blocksRoom(&b) {
+		if state, err := checkOpeningHours(tx, b.RoomID, b.StartTime, b.EndTime); err != nil {
+			return nil, state, err
+		}
+		if state, err := checkConflict(tx, b.RoomID, b.PartySize, b.StartTime, b.EndTime); err != nil {
+			return nil, state, err
+		}
+	}
+	if state, err := checkDocument(tx, &b); err != nil {
+		return nil, state, err
+	}
+	// the price is kept when the rates change later on
+	quote, err := quoteStay(tx, b.RoomID, b.StartTime, b.EndTime)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if quote != nil {
+		b.RatePlanID = &quote.RatePlanID
+		b.TotalPrice = &quote.Total
+		b.Currency = &quote.Currency
+	}
+	// a new booking has no payment yet, a priced one is booked once its payment is authorized
+	if b.State == "booked" {
+		if state, err := checkPayment(tx, &b); err != nil {
+			return nil, state, err
+		}
+	}
+	_, err = tx.NamedExec(`
+		INSERT INTO bookings (id, room_id, customer_id, requestor_id, requested_at, start_time, end_time,
+			state, state_information, file_name, description, reference, series_id,
+			booking_request_email, booking_request_from_email, expires_at, party_size,
+			rate_plan_id, total_price, currency, awaiting_since)
+		VALUES (:id, :room_id, :customer_id, :requestor_id, :requested_at, :start_time, :end_time,
+			:state, :state_information, :file_name, :description, :reference, :series_id,
+			:booking_request_email, :booking_request_from_email, :expires_at, :party_size,
+			:rate_plan_id, :total_price, :currency, :awaiting_since)`, &b)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err := tx.emit(events.BookingCreated, &b, ""); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &b, http.StatusOK, nil
+}
+
+// PatchBooking updates the booking of the customer, or of a room of the provider, depending on the role
+func PatchBooking(body *BookingPatch, id uuid.UUID, role string, ownerID uuid.UUID) (*Booking, int, error) {
+	var res *Booking
+	state, err := inTx(func(tx *txn) (int, error) {
+		if _, state, err := bookingAccess(tx, id, role, ownerID, true); err != nil {
+			return state, err
+		}
+		var state int
+		var err error
+		res, state, err = patchBooking(tx, body, id)
+		return state, err
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	if err := localizeBooking(res); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return res, http.StatusOK, nil
 }
 
-// PatchBooking ...
-func PatchBooking(body *BookingPatch, id uuid.UUID) (*Booking, int, error) {
+// patchBooking updates the booking, the bookings listed in exclude are not taken into
+// account at conflict detection
+func patchBooking(tx *txn, body *BookingPatch, id uuid.UUID, exclude ...uuid.UUID) (*Booking, int, error) {
+	sets := []string{}
+	args := []interface{}{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if body.RoomID != nil {
+		set("room_id", *body.RoomID)
+	}
+	if body.RequestorID != nil {
+		set("requestor_id", *body.RequestorID)
+	}
+	if body.RequestedAt != nil {
+		set("requested_at", *body.RequestedAt)
+	}
+	if body.StartTime != nil {
+		set("start_time", *body.StartTime)
+	}
+	if body.EndTime != nil {
+		set("end_time", *body.EndTime)
+	}
+	if body.State != nil {
+		set("state", *body.State)
+		if *body.State != "draft" {
+			// a hold leaving the draft state is kept
+			set("expires_at", nil)
+		}
+	}
+	if body.StateInfo != nil {
+		set("state_information", *body.StateInfo)
+	}
+	if body.BucketName != nil {
+		set("file_name", *body.BucketName)
+	}
+	if body.Description != nil {
+		set("description", *body.Description)
+	}
+	if body.Reference != nil {
+		set("reference", *body.Reference)
+	}
+	if body.BookingRequestEmail != nil {
+		set("booking_request_email", *body.BookingRequestEmail)
+	}
+	if body.BookingRequestFromEmail != nil {
+		set("booking_request_from_email", *body.BookingRequestFromEmail)
+	}
+	if body.PartySize != nil {
+		if *body.PartySize <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("party_size must be positive")
+		}
+		set("party_size", *body.PartySize)
+	}
+
+	var previousState string
+	err := tx.Get(&previousState, `SELECT state FROM bookings WHERE id = $1 FOR UPDATE`, id)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("booking request %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if body.State != nil && isAwaitingState(*body.State) && !isAwaitingState(previousState) {
+		// the approval SLA runs from the time the booking starts awaiting the provider
+		set("awaiting_since", time.Now().UTC())
+		set("escalated_at", nil)
+	}
 
-	return nil, http.StatusOK, nil
+	var b Booking
+	if len(sets) == 0 {
+		err = tx.Get(&b, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1`, id)
+	} else {
+		args = append(args, id)
+		query := fmt.Sprintf(`UPDATE bookings SET %s WHERE id = $%d RETURNING `+bookingColumns,
+			strings.Join(sets, ", "), len(args))
+		err = tx.Get(&b, query, args...)
+	}
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("booking request %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if !b.StartTime.Before(b.EndTime) {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time must be before end_time")
+	}
+	moved := body.RoomID != nil || body.StartTime != nil || body.EndTime != nil || body.State != nil ||
+		body.PartySize != nil
+	if moved && blocksRoom(&b) {
+		if state, err := lockRoom(tx, b.RoomID); err != nil {
+			return nil, state, err
+		}
+		if state, err := checkOpeningHours(tx, b.RoomID, b.StartTime, b.EndTime); err != nil {
+			return nil, state, err
+		}
+		state, err := checkConflict(tx, b.RoomID, b.PartySize, b.StartTime, b.EndTime, append(exclude, b.ID)...)
+		if err != nil {
+			return nil, state, err
+		}
+	}
+	if body.RoomID != nil || body.StartTime != nil || body.EndTime != nil {
+		if state, err := requote(tx, &b); err != nil {
+			return nil, state, err
+		}
+	}
+	if body.State != nil || body.RoomID != nil {
+		if state, err := checkDocument(tx, &b); err != nil {
+			return nil, state, err
+		}
+	}
+	if body.State != nil && b.State == "booked" && previousState != "booked" {
+		if state, err := checkPayment(tx, &b); err != nil {
+			return nil, state, err
+		}
+	}
+	if body.State != nil && b.State == "cancelled" && previousState != "cancelled" {
+		if state, err := applyCancellation(tx, &b, previousState, body.WaiveCancellationFee); err != nil {
+			return nil, state, err
+		}
+	}
+	if len(sets) > 0 {
+		if err := tx.emit(events.BookingUpdated, &b, previousState); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	return &b, http.StatusOK, nil
 }
 
 // DeleteBookingSystem ...
 func DeleteBookingSystem(hotelID *uuid.UUID, bID uuid.UUID) error {
+	_, err := inTx(func(tx *txn) (int, error) {
+		return deleteBooking(tx, hotelID, bID)
+	})
+	return err
+}
 
-	return nil
+func deleteBooking(tx *txn, hotelID *uuid.UUID, bID uuid.UUID) (int, error) {
+	var b Booking
+	var err error
+	if hotelID == nil {
+		err = tx.Get(&b, `DELETE FROM bookings WHERE id = $1 RETURNING `+bookingColumns, bID)
+	} else {
+		err = tx.Get(&b, `
+			DELETE FROM bookings
+			WHERE id = $1 AND room_id IN (SELECT id FROM rooms WHERE hotel_id = $2)
+			RETURNING `+bookingColumns, bID, *hotelID)
+	}
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("booking request %s not found", bID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := tx.emit(events.BookingDeleted, &b, b.State); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusNoContent, nil
 }
 
 // Migrate does db migration up to the latest version
@@ -0,0 +1,79 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// blockingStates are the states in which a booking occupies its room
+var blockingStates = []string{"pending", "pending_resp", "booked"}
+
+func isBlockingState(state string) bool {
+	for _, s := range blockingStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// blocksRoom tells whether the booking occupies its room, an unexpired hold does too
+func blocksRoom(b *Booking) bool {
+	if b.State == "draft" && b.ExpiresAt != nil {
+		return b.ExpiresAt.After(time.Now().UTC())
+	}
+	return isBlockingState(b.State)
+}
+
+// lockRoom serializes the bookings of a room until the end of the transaction
+func lockRoom(tx *txn, roomID uuid.UUID) (int, error) {
+	var id uuid.UUID
+	err := tx.Get(&id, `SELECT id FROM rooms WHERE id = $1 FOR UPDATE`, roomID)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("room %s not found", roomID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// checkConflict fails with 409 when the room is blocked or has no room left for the party in the
+// [start, end) period because of the bookings and unexpired holds, a room which isn't shared is
+// taken by any booking whatever its party size, the bookings listed in exclude are not taken
+// into account
+func checkConflict(tx *txn, roomID uuid.UUID, partySize int16, start, end time.Time, exclude ...uuid.UUID) (int, error) {
+	shared, capacity, err := getRoomCapacity(tx, roomID)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("room %s not found", roomID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if shared && int(partySize) > capacity {
+		return http.StatusBadRequest, fmt.Errorf("a party of %d exceeds the capacity %d of room %s", partySize, capacity, roomID)
+	}
+	if state, err := checkBlocks(tx, roomID, start, end); err != nil {
+		return state, err
+	}
+	occupying, err := occupyingBookings(tx, roomID, start, end, exclude...)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(occupying) == 0 {
+		return http.StatusOK, nil
+	}
+	if !shared {
+		return http.StatusConflict, fmt.Errorf("room %s is already booked between %s and %s by booking request %s",
+			roomID, start.Format(time.RFC3339), end.Format(time.RFC3339), occupying[0].ID)
+	}
+	if left := capacity - peakOccupancy(occupying, start, end); left < int(partySize) {
+		return http.StatusConflict, fmt.Errorf("room %s has %d places left between %s and %s",
+			roomID, left, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,82 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// ErrEventConsumerLost is returned once the lease of a consumer expired or was taken over by
+// another instance, the consumer must stop without recording its position
+var ErrEventConsumerLost = errors.New("the lease of the event consumer is lost")
+
+// ClaimEventConsumer leases the consumer of the booking events log with the given name to the
+// owner for the duration, it returns false when another instance holds the lease. A new
+// consumer starts at the end of the log.
+func ClaimEventConsumer(name string, owner uuid.UUID, lease time.Duration) (EventCursor, bool, error) {
+	var cur EventCursor
+	_, err := database.Exec(`
+		INSERT INTO event_consumers (name, tx_id, event_id)
+		VALUES ($1, txid_snapshot_xmin(txid_current_snapshot()) - 1, $2)
+		ON CONFLICT (name) DO NOTHING`, name, int64(math.MaxInt64))
+	if err != nil {
+		return cur, false, err
+	}
+	err = database.Get(&cur, `
+		UPDATE event_consumers SET owner = $2, leased_until = now() + $3::interval
+		WHERE name = $1 AND (leased_until IS NULL OR leased_until < now())
+		RETURNING tx_id AS txid, event_id AS id`,
+		name, owner, leaseInterval(lease))
+	if err == sql.ErrNoRows {
+		return cur, false, nil
+	}
+	if err != nil {
+		return cur, false, err
+	}
+	return cur, true, nil
+}
+
+// RenewEventConsumer extends the lease of the owner, it fails with ErrEventConsumerLost when
+// the owner doesn't hold it anymore
+func RenewEventConsumer(name string, owner uuid.UUID, lease time.Duration) error {
+	return fencedConsumerExec(`UPDATE event_consumers SET leased_until = now() + $3::interval
+		WHERE name = $1 AND owner = $2 AND leased_until > now()`, name, owner, leaseInterval(lease))
+}
+
+// AdvanceEventConsumer records the position of the consumer after an event it handled, it
+// fails with ErrEventConsumerLost when the owner doesn't hold the lease anymore
+func AdvanceEventConsumer(name string, owner uuid.UUID, cur EventCursor) error {
+	return fencedConsumerExec(`UPDATE event_consumers SET tx_id = $3, event_id = $4
+		WHERE name = $1 AND owner = $2 AND leased_until > now()`, name, owner, cur.TxID, cur.ID)
+}
+
+// ReleaseEventConsumer ends the lease of the owner
+func ReleaseEventConsumer(name string, owner uuid.UUID) error {
+	_, err := database.Exec(`UPDATE event_consumers SET leased_until = NULL WHERE name = $1 AND owner = $2`,
+		name, owner)
+	return err
+}
+
+// fencedConsumerExec runs the update of a consumer restricted to its lease holder
+func fencedConsumerExec(query string, args ...interface{}) error {
+	res, err := database.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEventConsumerLost
+	}
+	return nil
+}
+
+func leaseInterval(lease time.Duration) string {
+	return fmt.Sprintf("%d seconds", int(lease.Seconds()))
+}
@@ -0,0 +1,99 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Document upload policies of the rooms
+const (
+	DocumentUnavailable = "unavailable"
+	DocumentOptional    = "optional"
+	DocumentRequired    = "required"
+)
+
+// GetDocumentPolicy returns the document upload policy of the room of the booking
+func GetDocumentPolicy(bookingID uuid.UUID) (string, int, error) {
+	var policy string
+	err := database.Get(&policy, `
+		SELECT r.document_upload FROM bookings b JOIN rooms r ON r.id = b.room_id
+		WHERE b.id = $1`, bookingID)
+	if err == sql.ErrNoRows {
+		return "", http.StatusNotFound, fmt.Errorf("booking request %s not found", bookingID)
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	return policy, http.StatusOK, nil
+}
+
+// GetBookingDocument returns the document of the booking
+func GetBookingDocument(bookingID uuid.UUID) (*BookingDocument, int, error) {
+	var doc BookingDocument
+	err := database.Get(&doc, `
+		SELECT booking_id, storage_key, file_name, content_type, size, sha256, uploaded_at
+		FROM booking_documents WHERE booking_id = $1`, bookingID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("booking request %s has no document", bookingID)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &doc, http.StatusOK, nil
+}
+
+// SaveBookingDocument stores the document metadata of the booking, it returns the storage
+// key of the replaced document if there was one
+func SaveBookingDocument(doc *BookingDocument) (string, error) {
+	var replaced string
+	_, err := inTx(func(tx *txn) (int, error) {
+		err := tx.Get(&replaced, `SELECT storage_key FROM booking_documents WHERE booking_id = $1 FOR UPDATE`, doc.BookingID)
+		if err != nil && err != sql.ErrNoRows {
+			return http.StatusInternalServerError, err
+		}
+		_, err = tx.NamedExec(`
+			INSERT INTO booking_documents (booking_id, storage_key, file_name, content_type, size, sha256, uploaded_at)
+			VALUES (:booking_id, :storage_key, :file_name, :content_type, :size, :sha256, :uploaded_at)
+			ON CONFLICT (booking_id) DO UPDATE SET storage_key = EXCLUDED.storage_key,
+				file_name = EXCLUDED.file_name, content_type = EXCLUDED.content_type,
+				size = EXCLUDED.size, sha256 = EXCLUDED.sha256, uploaded_at = EXCLUDED.uploaded_at`, doc)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		_, err = tx.Exec(`UPDATE bookings SET file_name = $1 WHERE id = $2`, doc.FileName, doc.BookingID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	})
+	return replaced, err
+}
+
+// checkDocument fails when the booking moves to pending without the document its room requires
+func checkDocument(tx *txn, b *Booking) (int, error) {
+	if b.State != "pending" {
+		return http.StatusOK, nil
+	}
+	var check struct {
+		Policy   string `db:"document_upload"`
+		Uploaded bool   `db:"uploaded"`
+	}
+	err := tx.Get(&check, `
+		SELECT r.document_upload,
+			EXISTS (SELECT 1 FROM booking_documents d WHERE d.booking_id = $2) AS uploaded
+		FROM rooms r WHERE r.id = $1`, b.RoomID, b.ID)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("room %s not found", b.RoomID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if check.Policy == DocumentRequired && !check.Uploaded {
+		return http.StatusUnprocessableEntity, fmt.Errorf(
+			"room %s requires a document, create the booking as draft and upload the document before it can be pending", b.RoomID)
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,135 @@
+package dbmodels
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Embeddable resources of a booking
+const (
+	EmbedRoom  = "room"
+	EmbedHotel = "hotel"
+)
+
+// EmbeddableResources lists the resources which can be embedded in a booking
+var EmbeddableResources = []string{EmbedRoom, EmbedHotel}
+
+// CheckEmbed fails on the first resource which can't be embedded in a booking
+func CheckEmbed(embed []string) error {
+	for _, e := range embed {
+		known := false
+		for _, r := range EmbeddableResources {
+			known = known || e == r
+		}
+		if !known {
+			return fmt.Errorf("unknown embedded resource %q", e)
+		}
+	}
+	return nil
+}
+
+// EmbedResources loads the requested resources (room, hotel) and attaches them
+// to the bookings. Every resource type is fetched with a single query.
+func EmbedResources(bookings []Booking, embed []string) error {
+	if err := CheckEmbed(embed); err != nil {
+		return err
+	}
+	var withRoom, withHotel bool
+	for _, e := range embed {
+		withRoom = withRoom || e == EmbedRoom
+		withHotel = withHotel || e == EmbedHotel
+	}
+	if len(bookings) == 0 || !(withRoom || withHotel) {
+		return nil
+	}
+
+	rooms, err := getRoomsByID(roomIDs(bookings))
+	if err != nil {
+		return err
+	}
+
+	var hotels map[uuid.UUID]*Hotel
+	if withHotel {
+		ids := []uuid.UUID{}
+		seen := map[uuid.UUID]bool{}
+		for _, r := range rooms {
+			if !seen[r.HotelID] {
+				seen[r.HotelID] = true
+				ids = append(ids, r.HotelID)
+			}
+		}
+		hotels, err = getHotelsByID(ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range bookings {
+		room, ok := rooms[bookings[i].RoomID]
+		if !ok {
+			continue
+		}
+		if withRoom {
+			bookings[i].Room = room
+		}
+		if withHotel {
+			bookings[i].Hotel = hotels[room.HotelID]
+		}
+	}
+	return nil
+}
+
+func roomIDs(bookings []Booking) []uuid.UUID {
+	ids := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for _, b := range bookings {
+		if !seen[b.RoomID] {
+			seen[b.RoomID] = true
+			ids = append(ids, b.RoomID)
+		}
+	}
+	return ids
+}
+
+func getRoomsByID(ids []uuid.UUID) (map[uuid.UUID]*Room, error) {
+	res := map[uuid.UUID]*Room{}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT id, name, hotel_id, COALESCE(type::text, '') AS type, reservation_max_time, available_from, available_to,
+			reservation_lead_time, is_shared, shared_nr_person, description
+		FROM rooms WHERE id IN (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	rooms := []Room{}
+	if err = database.Select(&rooms, database.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for i := range rooms {
+		res[rooms[i].ID] = &rooms[i]
+	}
+	return res, nil
+}
+
+func getHotelsByID(ids []uuid.UUID) (map[uuid.UUID]*Hotel, error) {
+	res := map[uuid.UUID]*Hotel{}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	query, args, err := sqlx.In(`SELECT id, name, data_center_id, description, time_zone FROM hotels WHERE id IN (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	hotels := []Hotel{}
+	if err = database.Select(&hotels, database.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for i := range hotels {
+		res[hotels[i].ID] = &hotels[i]
+	}
+	return res, nil
+}
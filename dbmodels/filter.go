@@ -0,0 +1,132 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Roles of the callers of the customer and of the provider APIs, they scope the bookings they reach
+const (
+	RoleCustomer = "customer"
+	RoleProvider = "provider"
+)
+
+func uuidStrings(ids []uuid.UUID) []string {
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, id.String())
+	}
+	return res
+}
+
+// where returns the WHERE clause of the filter for the bookings table aliased as b
+func (f BookingFilter) where() (string, []interface{}) {
+	conds := []string{"TRUE"}
+	args := []interface{}{}
+	add := func(cond string, value interface{}) {
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	switch f.Role {
+	case RoleCustomer:
+		add(`b.customer_id = $%d`, f.OwnerID)
+	case RoleProvider:
+		add(`b.room_id IN (SELECT r.id FROM rooms r WHERE r.provider = $%d)`, f.OwnerID)
+	}
+	if len(f.DataCenters) > 0 {
+		add(`b.room_id IN (SELECT r.id FROM rooms r JOIN hotels h ON h.id = r.hotel_id
+			WHERE h.data_center_id::text = ANY($%d))`, pq.Array(uuidStrings(f.DataCenters)))
+	}
+	if len(f.Customers) > 0 {
+		add(`b.customer_id::text = ANY($%d)`, pq.Array(uuidStrings(f.Customers)))
+	}
+	if len(f.Requestors) > 0 {
+		add(`b.requestor_id::text = ANY($%d)`, pq.Array(uuidStrings(f.Requestors)))
+	}
+	if len(f.Bookings) > 0 {
+		add(`b.id::text = ANY($%d)`, pq.Array(uuidStrings(f.Bookings)))
+	}
+	if len(f.States) > 0 {
+		add(`b.state = ANY($%d::states[])`, pq.Array(f.States))
+	}
+	if f.FromDate != nil {
+		add(`b.end_time >= $%d`, *f.FromDate)
+	}
+	if f.ToDate != nil {
+		add(`b.start_time < $%d`, *f.ToDate)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// bookingAccess returns the booking when it is a booking of the customer, or of a room of the
+// provider, depending on the role, 404 otherwise
+func bookingAccess(q sqlx.Queryer, bookingID uuid.UUID, role string, ownerID uuid.UUID, lock bool) (*Booking, int, error) {
+	var query string
+	switch role {
+	case RoleCustomer:
+		query = `SELECT ` + bookingColumns + ` FROM bookings WHERE id = $1 AND customer_id = $2`
+	case RoleProvider:
+		query = `SELECT ` + prefixColumns("b", bookingColumns) + ` FROM bookings b JOIN rooms r ON r.id = b.room_id
+			WHERE b.id = $1 AND r.provider = $2`
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown role %q", role)
+	}
+	if lock {
+		query += ` FOR UPDATE`
+		if role == RoleProvider {
+			query += ` OF b`
+		}
+	}
+	var b Booking
+	err := sqlx.Get(q, &b, query, bookingID, ownerID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("booking request %s not found", bookingID)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &b, http.StatusOK, nil
+}
+
+// CheckBookingAccess checks that the booking is a booking of the customer, or of a room of the
+// provider, depending on the role
+func CheckBookingAccess(bookingID uuid.UUID, role string, ownerID uuid.UUID) (int, error) {
+	_, state, err := bookingAccess(database, bookingID, role, ownerID, false)
+	return state, err
+}
+
+// StreamBookings calls fn with every booking matching the filter, the rows are read one by one
+func StreamBookings(filter BookingFilter, fn func(*Booking) error) error {
+	where, args := filter.where()
+	rows, err := database.Queryx(`SELECT `+bookingColumns+` FROM bookings b WHERE `+where+`
+		ORDER BY b.start_time, b.id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b Booking
+		if err = rows.StructScan(&b); err != nil {
+			return err
+		}
+		if err = fn(&b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// prefixColumns qualifies a comma separated column list with a table alias
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, p := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ", ")
+}
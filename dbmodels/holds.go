@@ -0,0 +1,102 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Hold durations
+const (
+	DefaultHoldDuration = 10 * time.Minute
+	MaxHoldDuration     = time.Hour
+)
+
+// holdExpiredInfo is the state information of the expired holds
+const holdExpiredInfo = "hold expired"
+
+// PostBookingHold creates a hold, a draft booking reserving its slot until it expires
+func PostBookingHold(body *BookingPost, duration time.Duration) (*Booking, int, error) {
+	if duration <= 0 || duration > MaxHoldDuration {
+		return nil, http.StatusBadRequest, fmt.Errorf("the hold duration must be between 1s and %s", MaxHoldDuration)
+	}
+	if body.RRule != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("a booking series can't be held")
+	}
+	expiresAt := time.Now().UTC().Add(duration)
+	body.State = "draft"
+	body.ExpiresAt = &expiresAt
+	return PostBooking(body)
+}
+
+// ConfirmBookingHold turns the unexpired hold of the customer into a booking request
+// in the given state, pending or booked
+func ConfirmBookingHold(id, customerID uuid.UUID, state string) (*Booking, int, error) {
+	if state == "" {
+		state = "pending"
+	}
+	if state != "pending" && state != "booked" {
+		return nil, http.StatusBadRequest, fmt.Errorf("a hold can be confirmed as 'pending' or 'booked'")
+	}
+	var res *Booking
+	status, err := inTx(func(tx *txn) (int, error) {
+		var hold Booking
+		err := tx.Get(&hold, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1 AND customer_id = $2 FOR UPDATE`,
+			id, customerID)
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, fmt.Errorf("booking request %s not found", id)
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if hold.State != "draft" || hold.ExpiresAt == nil {
+			return http.StatusConflict, fmt.Errorf("booking request %s is not a hold", id)
+		}
+		if !hold.ExpiresAt.After(time.Now().UTC()) {
+			return http.StatusGone, fmt.Errorf("the hold of booking request %s expired", id)
+		}
+		var status int
+		res, status, err = patchBooking(tx, &BookingPatch{State: &state}, id)
+		return status, err
+	})
+	if err != nil {
+		return nil, status, err
+	}
+	if err := localizeBooking(res); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return res, http.StatusOK, nil
+}
+
+// ExpireHolds cancels up to limit expired holds, it returns the number of cancelled holds
+func ExpireHolds(limit int) (int, error) {
+	var expired []Booking
+	_, err := inTx(func(tx *txn) (int, error) {
+		err := tx.Select(&expired, `
+			UPDATE bookings SET state = 'cancelled', state_information = $1, expires_at = NULL
+			WHERE id IN (
+				SELECT id FROM bookings
+				WHERE state = 'draft' AND expires_at <= $2
+				ORDER BY expires_at
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED)
+			RETURNING `+bookingColumns, holdExpiredInfo, time.Now().UTC(), limit)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		for i := range expired {
+			if err := tx.emit(events.BookingExpired, &expired[i], "draft"); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,115 @@
+package dbmodels
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var roomTypes = map[string]bool{
+	"single": true, "double": true, "triple": true, "quad": true, "queen": true,
+	"king": true, "twin": true, "studio": true, "suite": true, "min_suite": true,
+}
+
+var timeOfDay = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9](:[0-5][0-9])?$`)
+
+// ValidateRoom checks the rules every new room has to follow
+func ValidateRoom(room *Room) error {
+	if room.Name == "" {
+		return fmt.Errorf("name is mandatory")
+	}
+	if room.HotelID == uuid.Nil {
+		return fmt.Errorf("hotel_id is mandatory")
+	}
+	if room.Type != "" && !roomTypes[room.Type] {
+		return fmt.Errorf("invalid room type %q", room.Type)
+	}
+	if !timeOfDay.MatchString(room.AvailableFrom) || !timeOfDay.MatchString(room.AvailableTo) {
+		return fmt.Errorf("available_from and available_to must be times of the day like 08:00")
+	}
+	if room.IsShared && (room.SharedNrPerson == nil || *room.SharedNrPerson <= 0) {
+		return fmt.Errorf("shared_nr_person is mandatory for shared rooms")
+	}
+	return nil
+}
+
+// Importer writes imported records in a single transaction, a failing record is
+// rolled back alone and does not affect the others
+type Importer struct {
+	tx *txn
+}
+
+// NewImporter starts an import
+func NewImporter() (*Importer, error) {
+	tx, err := begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Importer{tx: tx}, nil
+}
+
+// record runs fn within a savepoint
+func (im *Importer) record(fn func() (int, error)) (int, error) {
+	if _, err := im.tx.Exec(`SAVEPOINT import_record`); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	emitted := len(im.tx.events)
+	state, err := fn()
+	if err != nil {
+		if _, rerr := im.tx.Exec(`ROLLBACK TO SAVEPOINT import_record`); rerr != nil {
+			return http.StatusInternalServerError, rerr
+		}
+		im.tx.events = im.tx.events[:emitted]
+		return state, err
+	}
+	if _, err = im.tx.Exec(`RELEASE SAVEPOINT import_record`); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return state, nil
+}
+
+// Room imports a room, a new ID is assigned when it has none
+func (im *Importer) Room(room *Room) (int, error) {
+	if err := ValidateRoom(room); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if room.ID == uuid.Nil {
+		room.ID = uuid.NewV4()
+	}
+	return im.record(func() (int, error) {
+		_, err := im.tx.NamedExec(`
+			INSERT INTO rooms (id, name, hotel_id, type, reservation_max_time, available_from, available_to,
+				reservation_lead_time, is_shared, shared_nr_person, description)
+			VALUES (:id, :name, :hotel_id, NULLIF(:type, '')::roomtype, :reservation_max_time, :available_from,
+				:available_to, :reservation_lead_time, :is_shared, :shared_nr_person, :description)`, room)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusOK, nil
+	})
+}
+
+// Booking imports a booking with the rules of PostBooking
+func (im *Importer) Booking(body *BookingPost) (*Booking, int, error) {
+	if body.CustomerID == uuid.Nil || body.RequestorID == uuid.Nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("customer_id and requestor_id are mandatory")
+	}
+	var b *Booking
+	state, err := im.record(func() (int, error) {
+		var state int
+		var err error
+		b, state, err = postBooking(im.tx, body)
+		return state, err
+	})
+	return b, state, err
+}
+
+// Finish commits the import, or rolls it back when commit is false
+func (im *Importer) Finish(commit bool) error {
+	if !commit {
+		return im.tx.Rollback()
+	}
+	return im.tx.commit()
+}
@@ -0,0 +1,111 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Advisory lock keys of the scheduled transitions, each one runs in a single instance at a time
+const (
+	completeBookingsLock  int64 = 7301
+	closeStalePendingLock int64 = 7302
+	rejectOverdueLock     int64 = 7303
+	escalateOverdueLock   int64 = 7304
+)
+
+// stalePendingStateInfo is the state information of the bookings closed by CloseStalePending
+const stalePendingStateInfo = "not answered before the start time"
+
+// StalePendingStates are the states CloseStalePending may move the bookings to
+var StalePendingStates = []string{"rejected", "cancelled"}
+
+// transitionedBooking is a booking moved to another state along with its previous state
+type transitionedBooking struct {
+	Booking
+	PreviousState string `db:"previous_state"`
+}
+
+// CompleteBookings moves up to limit booked bookings which ended to completed, it returns
+// the number of completed bookings, 0 when another instance is running the transition
+func CompleteBookings(limit int) (int, error) {
+	return transitionBookings(completeBookingsLock, events.BookingUpdated, `
+		WITH due AS (
+			SELECT id, state FROM bookings
+			WHERE state = 'booked' AND end_time <= $1
+			ORDER BY end_time
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED)
+		UPDATE bookings b SET state = 'completed'
+		FROM due WHERE b.id = due.id
+		RETURNING `+prefixColumns("b", bookingColumns)+`, due.state AS previous_state`,
+		time.Now().UTC(), limit)
+}
+
+// CloseStalePending moves up to limit pending bookings which started without being answered
+// to the given terminal state, it returns the number of moved bookings, 0 when another
+// instance is running the transition
+func CloseStalePending(state string, limit int) (int, error) {
+	if !isStalePendingState(state) {
+		return 0, fmt.Errorf("stale pending bookings can't be moved to %q", state)
+	}
+	return transitionBookings(closeStalePendingLock, events.BookingUpdated, `
+		WITH due AS (
+			SELECT id, state FROM bookings
+			WHERE state::text = ANY($1) AND start_time <= $2
+			ORDER BY start_time
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED)
+		UPDATE bookings b SET state = $4, state_information = $5
+		FROM due WHERE b.id = due.id
+		RETURNING `+prefixColumns("b", bookingColumns)+`, due.state AS previous_state`,
+		pq.Array([]string{"pending", "pending_resp"}), time.Now().UTC(), limit, state, stalePendingStateInfo)
+}
+
+func isStalePendingState(state string) bool {
+	for _, s := range StalePendingStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionBookings runs the transition query holding the advisory lock and records an
+// event of the given type per returned booking, the cancelled bookings get their cancellation
+// time and policy like the ones cancelled through the API
+func transitionBookings(lock int64, eventType string, query string, args ...interface{}) (int, error) {
+	var moved []transitionedBooking
+	_, err := inTx(func(tx *txn) (int, error) {
+		var locked bool
+		if err := tx.Get(&locked, `SELECT pg_try_advisory_xact_lock($1)`, lock); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !locked {
+			return http.StatusOK, nil
+		}
+		if err := tx.Select(&moved, query, args...); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		for i := range moved {
+			b := &moved[i].Booking
+			if b.State == "cancelled" && moved[i].PreviousState != "cancelled" {
+				// the request was never answered, it is cancelled without a fee
+				if state, err := applyCancellation(tx, b, moved[i].PreviousState, true); err != nil {
+					return state, err
+				}
+			}
+			if err := tx.emit(eventType, &moved[i].Booking, moved[i].PreviousState); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(moved), nil
+}
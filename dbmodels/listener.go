@@ -0,0 +1,114 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// BookingEventsChannel is the Postgres notification channel of the booking events
+const BookingEventsChannel = "booking_events"
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPing         = 90 * time.Second
+	listenerPoll         = time.Second
+	listenerCatchUpBatch = 500
+)
+
+// Listener receives the booking event notifications of all the instances sharing the
+// database and publishes the events to the local subscribers. The events missed while
+// the connection was down are read from the events log after reconnecting.
+//
+// The notifications only wake the listener up, the events are read from the log in commit
+// order. The log is polled as well: the events held back by an older running transaction
+// are released without a notification when it ends.
+type Listener struct {
+	l      *pq.Listener
+	cursor EventCursor
+	done   chan struct{}
+}
+
+// ListenBookingEvents starts the listener, from then on the committed events are published
+// by the listener only
+func ListenBookingEvents(conf Config) (*Listener, error) {
+	li := &Listener{done: make(chan struct{})}
+	li.l = pq.NewListener(connString(conf), listenerMinReconnect, listenerMaxReconnect, li.onConnection)
+	if err := li.l.Listen(BookingEventsChannel); err != nil {
+		li.l.Close()
+		return nil, err
+	}
+	cursor, err := GetBookingEventsHead()
+	if err != nil {
+		li.l.Close()
+		return nil, err
+	}
+	li.cursor = cursor
+	atomic.StoreInt32(&localPublish, 0)
+	go li.run()
+	return li, nil
+}
+
+// Close stops the listener, the committed events are published locally again
+func (li *Listener) Close() error {
+	close(li.done)
+	atomic.StoreInt32(&localPublish, 1)
+	return li.l.Close()
+}
+
+func (li *Listener) onConnection(ev pq.ListenerEventType, err error) {
+	switch ev {
+	case pq.ListenerEventDisconnected:
+		log.Warnf("Booking events listener disconnected: %v", err)
+	case pq.ListenerEventReconnected:
+		log.Info("Booking events listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Errorf("Booking events listener failed to reconnect: %v", err)
+	}
+}
+
+func (li *Listener) run() {
+	ping := time.NewTicker(listenerPing)
+	defer ping.Stop()
+	poll := time.NewTicker(listenerPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-li.done:
+			return
+		case <-li.l.Notify:
+			// nil is sent after a reconnection, the events in between are read from the log too
+			li.catchUp()
+		case <-poll.C:
+			li.catchUp()
+		case <-ping.C:
+			go func() {
+				if err := li.l.Ping(); err != nil {
+					log.Warnf("Booking events listener ping failed: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// catchUp publishes the events of the log following the cursor
+func (li *Listener) catchUp() {
+	for {
+		evs, err := GetBookingEventsAfter(li.cursor, listenerCatchUpBatch)
+		if err != nil {
+			log.Errorf("Failed to read the booking events: %v", err)
+			return
+		}
+		for i := range evs {
+			events.Publish(evs[i])
+			li.cursor = CursorOf(&evs[i])
+		}
+		if len(evs) < listenerCatchUpBatch {
+			return
+		}
+	}
+}
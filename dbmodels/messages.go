@@ -0,0 +1,103 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Roles of the authors of the booking messages
+const (
+	MessageFromCustomer = RoleCustomer
+	MessageFromProvider = RoleProvider
+)
+
+// MaxBookingMessageLength is the maximum length of the body of a booking message
+const MaxBookingMessageLength = 10000
+
+const bookingMessageColumns = `id, booking_id, author_role, author_id, body, attachment_key, attachment_name,
+	attachment_type, attachment_size, attachment_sha256, created_at, read_at`
+
+// PostBookingMessage adds the message to the thread of its booking, the booking.message event
+// carries it to the other side
+func PostBookingMessage(ownerID uuid.UUID, m *BookingMessage) (int, error) {
+	m.Body = strings.TrimSpace(m.Body)
+	if m.Body == "" && m.AttachmentKey == nil {
+		return http.StatusBadRequest, fmt.Errorf("either a body or an attachment is mandatory")
+	}
+	if len(m.Body) > MaxBookingMessageLength {
+		return http.StatusBadRequest, fmt.Errorf("messages are limited to %d bytes", MaxBookingMessageLength)
+	}
+	if m.ID == uuid.Nil {
+		m.ID = uuid.NewV4()
+	}
+	m.CreatedAt = time.Now().UTC()
+	m.ReadAt = nil
+	return inTx(func(tx *txn) (int, error) {
+		b, state, err := bookingAccess(tx, m.BookingID, m.AuthorRole, ownerID, true)
+		if err != nil {
+			return state, err
+		}
+		_, err = tx.NamedExec(`
+			INSERT INTO booking_messages (`+bookingMessageColumns+`)
+			VALUES (:id, :booking_id, :author_role, :author_id, :body, :attachment_key, :attachment_name,
+				:attachment_type, :attachment_size, :attachment_sha256, :created_at, :read_at)`, m)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		b.Message = m
+		if err := tx.emit(events.BookingMessage, b, b.State); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	})
+}
+
+// GetBookingMessages returns the thread of the booking, the first message first. The messages of
+// the other side are marked as read by the reader.
+func GetBookingMessages(bookingID uuid.UUID, role string, ownerID uuid.UUID) ([]BookingMessage, int, error) {
+	messages := []BookingMessage{}
+	state, err := inTx(func(tx *txn) (int, error) {
+		if _, state, err := bookingAccess(tx, bookingID, role, ownerID, false); err != nil {
+			return state, err
+		}
+		_, err := tx.Exec(`UPDATE booking_messages SET read_at = $3
+			WHERE booking_id = $1 AND author_role <> $2 AND read_at IS NULL`,
+			bookingID, role, time.Now().UTC())
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		err = tx.Select(&messages, `SELECT `+bookingMessageColumns+` FROM booking_messages
+			WHERE booking_id = $1 ORDER BY created_at, id`, bookingID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	return messages, http.StatusOK, nil
+}
+
+// GetBookingMessage returns a message of the thread of the booking
+func GetBookingMessage(bookingID, id uuid.UUID, role string, ownerID uuid.UUID) (*BookingMessage, int, error) {
+	if _, state, err := bookingAccess(database, bookingID, role, ownerID, false); err != nil {
+		return nil, state, err
+	}
+	var m BookingMessage
+	err := database.Get(&m, `SELECT `+bookingMessageColumns+` FROM booking_messages
+		WHERE id = $1 AND booking_id = $2`, id, bookingID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("message %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &m, http.StatusOK, nil
+}
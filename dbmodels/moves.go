@@ -0,0 +1,161 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const bookingMoveColumns = `id, booking_id, from_room_id, to_room_id, swapped_with, reason, moved_by, moved_at`
+
+// MoveBooking moves the booking to another room of the provider, of the same type unless AnyType
+// is set, the price of the booking is kept. With SwapWith the two bookings exchange their rooms
+// atomically. The moved bookings are returned.
+func MoveBooking(providerID, userID, id uuid.UUID, body *BookingMove) ([]Booking, int, error) {
+	if body.RoomID == nil && body.SwapWith == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("either room_id or swap_with is mandatory")
+	}
+	if body.SwapWith != nil && *body.SwapWith == id {
+		return nil, http.StatusBadRequest, fmt.Errorf("a booking request can't be swapped with itself")
+	}
+	var moved []Booking
+	state, err := inTx(func(tx *txn) (int, error) {
+		bookings := []Booking{}
+		ids := []uuid.UUID{id}
+		if body.SwapWith != nil {
+			ids = append(ids, *body.SwapWith)
+		}
+		for _, bookingID := range ids {
+			var b Booking
+			err := tx.Get(&b, `SELECT `+bookingColumns+` FROM bookings WHERE id = $1 FOR UPDATE`, bookingID)
+			if err == sql.ErrNoRows {
+				return http.StatusNotFound, fmt.Errorf("booking request %s not found", bookingID)
+			}
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			bookings = append(bookings, b)
+		}
+
+		targets := []uuid.UUID{}
+		if body.SwapWith != nil {
+			if body.RoomID != nil && *body.RoomID != bookings[1].RoomID {
+				return http.StatusBadRequest, fmt.Errorf("room_id must be the room of the swapped booking request")
+			}
+			targets = append(targets, bookings[1].RoomID, bookings[0].RoomID)
+		} else {
+			targets = append(targets, *body.RoomID)
+		}
+		if targets[0] == bookings[0].RoomID {
+			return http.StatusBadRequest, fmt.Errorf("booking request %s is already in room %s", id, targets[0])
+		}
+		if state, err := checkMoveRooms(tx, providerID, bookings[0].RoomID, targets[0], body.AnyType); err != nil {
+			return state, err
+		}
+		// the rooms are locked in the same order by concurrent moves
+		rooms := []uuid.UUID{bookings[0].RoomID, targets[0]}
+		if rooms[1].String() < rooms[0].String() {
+			rooms[0], rooms[1] = rooms[1], rooms[0]
+		}
+		for _, roomID := range rooms {
+			if state, err := lockRoom(tx, roomID); err != nil {
+				return state, err
+			}
+		}
+
+		for i := range bookings {
+			b := &bookings[i]
+			if blocksRoom(b) {
+				if state, err := checkOpeningHours(tx, targets[i], b.StartTime, b.EndTime); err != nil {
+					return state, err
+				}
+				if state, err := checkConflict(tx, targets[i], b.PartySize, b.StartTime, b.EndTime, ids...); err != nil {
+					return state, err
+				}
+			}
+		}
+		for i := range bookings {
+			var swappedWith *uuid.UUID
+			if len(bookings) == 2 {
+				swappedWith = &bookings[1-i].ID
+			}
+			b, state, err := moveBooking(tx, &bookings[i], targets[i], swappedWith, userID, body.Reason)
+			if err != nil {
+				return state, err
+			}
+			moved = append(moved, *b)
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	if err := localize(moved); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return moved, http.StatusOK, nil
+}
+
+// checkMoveRooms checks that both rooms are rooms of the provider of the same type
+func checkMoveRooms(tx *txn, providerID, from, to uuid.UUID, anyType bool) (int, error) {
+	types := map[uuid.UUID]string{}
+	for _, roomID := range []uuid.UUID{from, to} {
+		var roomType string
+		err := tx.Get(&roomType, `SELECT COALESCE(type::text, '') FROM rooms WHERE id = $1 AND provider = $2`,
+			roomID, providerID)
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, fmt.Errorf("room %s not found", roomID)
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		types[roomID] = roomType
+	}
+	if !anyType && types[from] != types[to] {
+		return http.StatusBadRequest, fmt.Errorf("room %s is of type %q instead of %q", to, types[to], types[from])
+	}
+	return http.StatusOK, nil
+}
+
+// moveBooking updates the room of the checked booking and records the move
+func moveBooking(tx *txn, b *Booking, roomID uuid.UUID, swappedWith *uuid.UUID, userID uuid.UUID, reason *string) (*Booking, int, error) {
+	from := b.RoomID
+	var res Booking
+	err := tx.Get(&res, `UPDATE bookings SET room_id = $2 WHERE id = $1 RETURNING `+bookingColumns, b.ID, roomID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if state, err := checkDocument(tx, &res); err != nil {
+		return nil, state, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO booking_moves (`+bookingMoveColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		uuid.NewV4(), res.ID, from, roomID, swappedWith, reason, userID, time.Now().UTC())
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	res.MovedFrom = &from
+	if err := tx.emit(events.BookingMoved, &res, res.State); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &res, http.StatusOK, nil
+}
+
+// GetBookingMoves returns the room moves of a booking of the provider, the first one first
+func GetBookingMoves(id, providerID uuid.UUID) ([]BookingRoomMove, int, error) {
+	if _, state, err := bookingAccess(database, id, RoleProvider, providerID, false); err != nil {
+		return nil, state, err
+	}
+	moves := []BookingRoomMove{}
+	err := database.Select(&moves, `SELECT `+bookingMoveColumns+` FROM booking_moves
+		WHERE booking_id = $1 ORDER BY moved_at`, id)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return moves, http.StatusOK, nil
+}
@@ -0,0 +1,216 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Payment states
+const (
+	PaymentAuthorizing = "authorizing"
+	PaymentAuthorized  = "authorized"
+	PaymentCaptured    = "captured"
+	PaymentRefunded    = "refunded"
+	PaymentVoided      = "voided"
+	PaymentFailed      = "failed"
+)
+
+// Payment operations
+const (
+	OpAuthorize = "authorize"
+	OpCapture   = "capture"
+	OpRefund    = "refund"
+	OpVoid      = "void"
+)
+
+// Payment transaction states, a transaction is in flight until the provider answered and
+// pending until the callback of an asynchronous result
+const (
+	TransactionInFlight  = "in_flight"
+	TransactionPending   = "pending"
+	TransactionSucceeded = "succeeded"
+	TransactionFailed    = "failed"
+)
+
+const paymentColumns = `id, booking_id, provider, reference, currency, amount, captured, refunded, status,
+	created_at, updated_at`
+
+const paymentTransactionColumns = `id, payment_id, operation, amount, status, reference, message,
+	created_at, updated_at`
+
+// CreatePayment records the payment of the booking before its authorization, it returns nil
+// when the booking already has a payment
+func CreatePayment(bookingID uuid.UUID, provider, currency string, amount int64) (*Payment, error) {
+	now := time.Now().UTC()
+	var p Payment
+	err := database.Get(&p, `
+		INSERT INTO payments (id, booking_id, provider, currency, amount, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (booking_id) DO NOTHING
+		RETURNING `+paymentColumns,
+		uuid.NewV4(), bookingID, provider, currency, amount, PaymentAuthorizing, now)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetBookingPayment returns the payment of the booking with its transactions
+func GetBookingPayment(bookingID uuid.UUID) (*Payment, int, error) {
+	var p Payment
+	err := database.Get(&p, `SELECT `+paymentColumns+` FROM payments WHERE booking_id = $1`, bookingID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("booking request %s has no payment", bookingID)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	err = database.Select(&p.Transactions, `SELECT `+paymentTransactionColumns+` FROM payment_transactions
+		WHERE payment_id = $1 ORDER BY created_at`, p.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &p, http.StatusOK, nil
+}
+
+// StartPaymentTransaction records an operation in flight before it is sent to the payment
+// provider, its ID is the idempotency key of the operation
+func StartPaymentTransaction(paymentID uuid.UUID, operation string, amount int64) (*PaymentTransaction, error) {
+	now := time.Now().UTC()
+	t := PaymentTransaction{
+		ID:        uuid.NewV4(),
+		PaymentID: paymentID,
+		Operation: operation,
+		Amount:    amount,
+		Status:    TransactionInFlight,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err := database.NamedExec(`
+		INSERT INTO payment_transactions (`+paymentTransactionColumns+`)
+		VALUES (:id, :payment_id, :operation, :amount, :status, :reference, :message, :created_at, :updated_at)`, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetInFlightPaymentTransactions returns the oldest transactions which weren't answered by
+// the payment provider
+func GetInFlightPaymentTransactions(limit int) ([]PaymentTransaction, error) {
+	ts := []PaymentTransaction{}
+	err := database.Select(&ts, `SELECT `+paymentTransactionColumns+` FROM payment_transactions
+		WHERE status = $1 ORDER BY created_at LIMIT $2`, TransactionInFlight, limit)
+	return ts, err
+}
+
+// GetPayment returns the payment without its transactions
+func GetPayment(id uuid.UUID) (*Payment, int, error) {
+	var p Payment
+	err := database.Get(&p, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("payment %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &p, http.StatusOK, nil
+}
+
+// GetPaymentTransactionByReference returns the transaction of the payment provider reference
+func GetPaymentTransactionByReference(reference string) (*PaymentTransaction, int, error) {
+	var t PaymentTransaction
+	err := database.Get(&t, `SELECT `+paymentTransactionColumns+` FROM payment_transactions WHERE reference = $1`,
+		reference)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("payment transaction %s not found", reference)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &t, http.StatusOK, nil
+}
+
+// CompletePaymentTransaction records the result of a transaction and applies a succeeded one to
+// its payment, the results of a transaction which isn't in flight or pending anymore are ignored
+func CompletePaymentTransaction(id uuid.UUID, status, reference, message string) (*Payment, error) {
+	var p Payment
+	_, err := inTx(func(tx *txn) (int, error) {
+		var t PaymentTransaction
+		err := tx.Get(&t, `SELECT `+paymentTransactionColumns+` FROM payment_transactions WHERE id = $1 FOR UPDATE`, id)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		err = tx.Get(&p, `SELECT `+paymentColumns+` FROM payments WHERE id = $1 FOR UPDATE`, t.PaymentID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if t.Status != TransactionInFlight && t.Status != TransactionPending {
+			return http.StatusOK, nil
+		}
+		now := time.Now().UTC()
+		_, err = tx.Exec(`
+			UPDATE payment_transactions SET status = $2, reference = COALESCE(NULLIF($3, ''), reference),
+				message = NULLIF($4, ''), updated_at = $5
+			WHERE id = $1`, id, status, reference, message, now)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		switch {
+		case status == TransactionSucceeded && t.Operation == OpAuthorize:
+			p.Status = PaymentAuthorized
+			if reference != "" {
+				p.Reference = &reference
+			}
+		case status == TransactionSucceeded && t.Operation == OpCapture:
+			p.Status = PaymentCaptured
+			p.Captured += t.Amount
+		case status == TransactionSucceeded && t.Operation == OpRefund:
+			p.Status = PaymentRefunded
+			p.Refunded += t.Amount
+		case status == TransactionSucceeded && t.Operation == OpVoid:
+			p.Status = PaymentVoided
+		case status == TransactionFailed && t.Operation == OpAuthorize:
+			p.Status = PaymentFailed
+		default:
+			return http.StatusOK, nil
+		}
+		p.UpdatedAt = now
+		_, err = tx.NamedExec(`
+			UPDATE payments SET reference = :reference, captured = :captured, refunded = :refunded,
+				status = :status, updated_at = :updated_at
+			WHERE id = :id`, &p)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// checkPayment fails with 402 when the priced booking has no authorized payment
+func checkPayment(tx *txn, b *Booking) (int, error) {
+	if b.TotalPrice == nil || *b.TotalPrice == 0 {
+		return http.StatusOK, nil
+	}
+	var status string
+	err := tx.Get(&status, `SELECT status FROM payments WHERE booking_id = $1`, b.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	if status != PaymentAuthorized && status != PaymentCaptured {
+		return http.StatusPaymentRequired, fmt.Errorf("booking request %s has no authorized payment", b.ID)
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,340 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Rate plan units
+const (
+	RateNight = "night"
+	RateHour  = "hour"
+)
+
+// MaxQuotePeriod is the longest stay priced by a quote
+const MaxQuotePeriod = 366 * 24 * time.Hour
+
+// seasonDateFormat is the format of the season dates and of the quote line dates
+const seasonDateFormat = "2006-01-02"
+
+const ratePlanColumns = `id, provider_id, hotel_id, room_id, room_type, name, currency, unit, base_rate,
+	weekend_rate, seasons, created_at, updated_at`
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Value stores the seasons as JSON
+func (s Seasons) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan reads the seasons from JSON
+func (s *Seasons) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case nil:
+		*s = nil
+		return nil
+	}
+	return fmt.Errorf("can't scan %T into seasons", src)
+}
+
+func validateRatePlan(body *RatePlanPost) error {
+	if body.Name == "" {
+		return fmt.Errorf("name is mandatory")
+	}
+	if !currencyCode.MatchString(body.Currency) {
+		return fmt.Errorf("currency must be an ISO 4217 code like EUR")
+	}
+	if body.Unit != RateNight && body.Unit != RateHour {
+		return fmt.Errorf("unit must be %q or %q", RateNight, RateHour)
+	}
+	if body.BaseRate < 0 || (body.WeekendRate != nil && *body.WeekendRate < 0) {
+		return fmt.Errorf("rates can't be negative")
+	}
+	if (body.RoomID == nil) == (body.RoomType == nil) {
+		return fmt.Errorf("either room_id or room_type is mandatory")
+	}
+	if body.RoomType != nil && body.HotelID == nil {
+		return fmt.Errorf("hotel_id is mandatory with room_type")
+	}
+	for _, season := range body.Seasons {
+		from, err := time.Parse(seasonDateFormat, season.From)
+		if err != nil {
+			return fmt.Errorf("invalid season from date %q", season.From)
+		}
+		to, err := time.Parse(seasonDateFormat, season.To)
+		if err != nil {
+			return fmt.Errorf("invalid season to date %q", season.To)
+		}
+		if to.Before(from) {
+			return fmt.Errorf("season %q ends before it starts", season.Name)
+		}
+		if season.Rate < 0 || (season.WeekendRate != nil && *season.WeekendRate < 0) {
+			return fmt.Errorf("rates can't be negative")
+		}
+	}
+	return nil
+}
+
+// providerHotel returns the hotel of the room of the provider, or the hotel itself when it has
+// rooms of the provider
+func providerHotel(providerID uuid.UUID, roomID, hotelID *uuid.UUID) (uuid.UUID, int, error) {
+	var id uuid.UUID
+	var err error
+	if roomID != nil {
+		err = database.Get(&id, `SELECT hotel_id FROM rooms WHERE id = $1 AND provider = $2`, *roomID, providerID)
+		if err == sql.ErrNoRows {
+			return id, http.StatusNotFound, fmt.Errorf("room %s not found", *roomID)
+		}
+	} else {
+		err = database.Get(&id, `SELECT DISTINCT hotel_id FROM rooms WHERE hotel_id = $1 AND provider = $2`,
+			*hotelID, providerID)
+		if err == sql.ErrNoRows {
+			return id, http.StatusNotFound, fmt.Errorf("hotel %s not found", *hotelID)
+		}
+	}
+	if err != nil {
+		return id, http.StatusInternalServerError, err
+	}
+	return id, http.StatusOK, nil
+}
+
+// ratePlanError maps the constraint violations of a rate plan write to a status
+func ratePlanError(err error) (int, error) {
+	switch {
+	case strings.Contains(err.Error(), "duplicate key"):
+		return http.StatusConflict, fmt.Errorf("the room or room type already has a rate plan")
+	case strings.Contains(err.Error(), "invalid input value for enum"):
+		return http.StatusBadRequest, fmt.Errorf("unknown room type")
+	}
+	return http.StatusInternalServerError, err
+}
+
+// CreateRatePlan creates a rate plan of the provider
+func CreateRatePlan(providerID uuid.UUID, body *RatePlanPost) (*RatePlan, int, error) {
+	if err := validateRatePlan(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	hotelID, state, err := providerHotel(providerID, body.RoomID, body.HotelID)
+	if err != nil {
+		return nil, state, err
+	}
+	now := time.Now().UTC()
+	p := RatePlan{
+		ID:          uuid.NewV4(),
+		ProviderID:  providerID,
+		HotelID:     hotelID,
+		RoomID:      body.RoomID,
+		RoomType:    body.RoomType,
+		Name:        body.Name,
+		Currency:    body.Currency,
+		Unit:        body.Unit,
+		BaseRate:    body.BaseRate,
+		WeekendRate: body.WeekendRate,
+		Seasons:     body.Seasons,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	_, err = database.NamedExec(`
+		INSERT INTO rate_plans (`+ratePlanColumns+`)
+		VALUES (:id, :provider_id, :hotel_id, :room_id, :room_type, :name, :currency, :unit, :base_rate,
+			:weekend_rate, :seasons, :created_at, :updated_at)`, &p)
+	if err != nil {
+		state, err := ratePlanError(err)
+		return nil, state, err
+	}
+	return &p, http.StatusOK, nil
+}
+
+// UpdateRatePlan replaces a rate plan of the provider, the prices of the existing bookings don't change
+func UpdateRatePlan(providerID, id uuid.UUID, body *RatePlanPost) (*RatePlan, int, error) {
+	if err := validateRatePlan(body); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	hotelID, state, err := providerHotel(providerID, body.RoomID, body.HotelID)
+	if err != nil {
+		return nil, state, err
+	}
+	var p RatePlan
+	err = database.Get(&p, `
+		UPDATE rate_plans SET hotel_id = $3, room_id = $4, room_type = $5, name = $6, currency = $7, unit = $8,
+			base_rate = $9, weekend_rate = $10, seasons = $11, updated_at = $12
+		WHERE id = $1 AND provider_id = $2
+		RETURNING `+ratePlanColumns,
+		id, providerID, hotelID, body.RoomID, body.RoomType, body.Name, body.Currency, body.Unit,
+		body.BaseRate, body.WeekendRate, body.Seasons, time.Now().UTC())
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("rate plan %s not found", id)
+	}
+	if err != nil {
+		state, err := ratePlanError(err)
+		return nil, state, err
+	}
+	return &p, http.StatusOK, nil
+}
+
+// GetRatePlans lists the rate plans of the provider
+func GetRatePlans(providerID uuid.UUID) ([]RatePlan, error) {
+	plans := []RatePlan{}
+	err := database.Select(&plans, `SELECT `+ratePlanColumns+` FROM rate_plans
+		WHERE provider_id = $1 ORDER BY hotel_id, name`, providerID)
+	return plans, err
+}
+
+// DeleteRatePlan removes a rate plan of the provider, the prices of the existing bookings are kept
+func DeleteRatePlan(providerID, id uuid.UUID) (int, error) {
+	res, err := database.Exec(`DELETE FROM rate_plans WHERE id = $1 AND provider_id = $2`, id, providerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("rate plan %s not found", id)
+	}
+	return http.StatusNoContent, nil
+}
+
+// GetQuote prices a stay in the room from start to end
+func GetQuote(roomID uuid.UUID, start, end time.Time) (*Quote, int, error) {
+	if !start.Before(end) {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time must be before end_time")
+	}
+	if end.Sub(start) > MaxQuotePeriod {
+		return nil, http.StatusBadRequest, fmt.Errorf("stays of %s at most are priced", MaxQuotePeriod)
+	}
+	quote, err := quoteStay(database, roomID, start, end)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if quote == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("room %s has no rate plan", roomID)
+	}
+	return quote, http.StatusOK, nil
+}
+
+// quoteStay prices the stay with the rate plan of the room, or else the one of its type in its
+// hotel, it returns nil when the room has no rate plan. The nights and the days are the ones
+// of the time zone of the hotel.
+func quoteStay(q sqlx.Queryer, roomID uuid.UUID, start, end time.Time) (*Quote, error) {
+	var plan struct {
+		RatePlan
+		TimeZone string `db:"time_zone"`
+	}
+	err := sqlx.Get(q, &plan, `
+		SELECT `+prefixColumns("p", ratePlanColumns)+`, COALESCE(h.time_zone, 'UTC') AS time_zone
+		FROM rate_plans p JOIN rooms r ON r.hotel_id = p.hotel_id LEFT JOIN hotels h ON h.id = r.hotel_id
+		WHERE r.id = $1 AND (p.room_id = r.id OR (p.room_type = r.type AND p.provider_id = r.provider))
+		ORDER BY p.room_id IS NULL
+		LIMIT 1`, roomID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(plan.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	quote := plan.price(start, end, loc)
+	quote.RoomID = roomID
+	return &quote, nil
+}
+
+// requote prices the booking again after its room or its times changed, with the rates in
+// force now. A room without a rate plan leaves the booking without a price.
+func requote(tx *txn, b *Booking) (int, error) {
+	quote, err := quoteStay(tx, b.RoomID, b.StartTime, b.EndTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	b.RatePlanID, b.TotalPrice, b.Currency = nil, nil, nil
+	if quote != nil {
+		b.RatePlanID = &quote.RatePlanID
+		b.TotalPrice = &quote.Total
+		b.Currency = &quote.Currency
+	}
+	_, err = tx.Exec(`UPDATE bookings SET rate_plan_id = $2, total_price = $3, currency = $4 WHERE id = $1`,
+		b.ID, b.RatePlanID, b.TotalPrice, b.Currency)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// price computes the price of the nights, or of the started hours, between start and end, the
+// dates are the local ones of loc
+func (p *RatePlan) price(start, end time.Time, loc *time.Location) Quote {
+	quote := Quote{
+		RatePlanID: p.ID,
+		StartTime:  start,
+		EndTime:    end,
+		Currency:   p.Currency,
+		Unit:       p.Unit,
+		Lines:      []QuoteLine{},
+	}
+	add := func(day time.Time) {
+		date := day.Format(seasonDateFormat)
+		n := len(quote.Lines)
+		if n == 0 || quote.Lines[n-1].Date != date {
+			rate, season := p.rate(day)
+			quote.Lines = append(quote.Lines, QuoteLine{Date: date, Rate: rate, Season: season})
+			n++
+		}
+		line := &quote.Lines[n-1]
+		line.Quantity++
+		line.Amount += line.Rate
+		quote.Total += line.Rate
+	}
+	start, end = start.In(loc), end.In(loc)
+	if p.Unit == RateHour {
+		for t := start; t.Before(end); t = t.Add(time.Hour) {
+			add(t)
+		}
+		return quote
+	}
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+	// a stay within a day is priced as a night
+	add(day)
+	for day = day.AddDate(0, 0, 1); day.Before(last); day = day.AddDate(0, 0, 1) {
+		add(day)
+	}
+	return quote
+}
+
+// rate returns the rate of the night or of the hours of the local day and the name of its season
+func (p *RatePlan) rate(day time.Time) (int64, string) {
+	weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+	if p.Unit == RateNight {
+		// the weekend nights are the ones of Friday and Saturday
+		weekend = day.Weekday() == time.Friday || day.Weekday() == time.Saturday
+	}
+	date := day.Format(seasonDateFormat)
+	for _, season := range p.Seasons {
+		if season.From <= date && date <= season.To {
+			if weekend && season.WeekendRate != nil {
+				return *season.WeekendRate, season.Name
+			}
+			return season.Rate, season.Name
+		}
+	}
+	if weekend && p.WeekendRate != nil {
+		return *p.WeekendRate, ""
+	}
+	return p.BaseRate, ""
+}
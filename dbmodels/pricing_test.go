@@ -0,0 +1,125 @@
+package dbmodels
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestPriceNightsInHotelTimeZone(t *testing.T) {
+	weekend := int64(150)
+	p := RatePlan{Unit: RateNight, Currency: "USD", BaseRate: 100, WeekendRate: &weekend}
+	ny := mustLoadLocation(t, "America/New_York")
+
+	// Friday 21:00 to Sunday 10:00 in New York, Saturday 02:00 to Sunday 15:00 UTC
+	start := time.Date(2019, 1, 5, 2, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 6, 15, 0, 0, 0, time.UTC)
+
+	q := p.price(start, end, ny)
+	if len(q.Lines) != 2 || q.Lines[0].Date != "2019-01-04" || q.Lines[1].Date != "2019-01-05" {
+		t.Fatalf("lines = %+v, want the nights of Friday and Saturday", q.Lines)
+	}
+	if q.Total != 300 {
+		t.Errorf("total = %d, want 300", q.Total)
+	}
+
+	q = p.price(start, end, time.UTC)
+	if len(q.Lines) != 1 || q.Total != 150 {
+		t.Errorf("UTC lines = %+v, total %d, want the night of Saturday", q.Lines, q.Total)
+	}
+}
+
+func TestPriceNights(t *testing.T) {
+	weekend := int64(150)
+	seasonWeekend := int64(250)
+	p := RatePlan{
+		Unit:        RateNight,
+		BaseRate:    100,
+		WeekendRate: &weekend,
+		Seasons: Seasons{
+			{Name: "summer", From: "2019-07-01", To: "2019-08-31", Rate: 200, WeekendRate: &seasonWeekend},
+		},
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		dates      []string
+		total      int64
+	}{
+		{
+			name:  "within a day",
+			start: time.Date(2019, 1, 8, 9, 0, 0, 0, time.UTC),
+			end:   time.Date(2019, 1, 8, 17, 0, 0, 0, time.UTC),
+			dates: []string{"2019-01-08"},
+			total: 100,
+		},
+		{
+			name:  "week nights and weekend nights",
+			start: time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC),
+			end:   time.Date(2019, 1, 13, 10, 0, 0, 0, time.UTC),
+			dates: []string{"2019-01-10", "2019-01-11", "2019-01-12"},
+			total: 100 + 150 + 150,
+		},
+		{
+			name:  "into the season",
+			start: time.Date(2019, 6, 29, 14, 0, 0, 0, time.UTC),
+			end:   time.Date(2019, 7, 2, 10, 0, 0, 0, time.UTC),
+			dates: []string{"2019-06-29", "2019-06-30", "2019-07-01"},
+			total: 150 + 100 + 200,
+		},
+		{
+			name:  "season weekend",
+			start: time.Date(2019, 7, 5, 14, 0, 0, 0, time.UTC),
+			end:   time.Date(2019, 7, 6, 10, 0, 0, 0, time.UTC),
+			dates: []string{"2019-07-05"},
+			total: 250,
+		},
+	}
+	for _, tt := range tests {
+		q := p.price(tt.start, tt.end, time.UTC)
+		if q.Total != tt.total {
+			t.Errorf("%s: total = %d, want %d", tt.name, q.Total, tt.total)
+		}
+		if len(q.Lines) != len(tt.dates) {
+			t.Errorf("%s: lines = %+v, want %v", tt.name, q.Lines, tt.dates)
+			continue
+		}
+		for i, date := range tt.dates {
+			if q.Lines[i].Date != date || q.Lines[i].Quantity != 1 {
+				t.Errorf("%s: line %d = %+v, want one night of %s", tt.name, i, q.Lines[i], date)
+			}
+		}
+	}
+}
+
+func TestPriceHoursAcrossDST(t *testing.T) {
+	p := RatePlan{Unit: RateHour, BaseRate: 10}
+	paris := mustLoadLocation(t, "Europe/Paris")
+
+	// 00:00 to 04:00 local time on the night the clocks go forward, three hours long
+	start := time.Date(2019, 3, 31, 0, 0, 0, 0, paris)
+	end := time.Date(2019, 3, 31, 4, 0, 0, 0, paris)
+	q := p.price(start, end, paris)
+	if len(q.Lines) != 1 || q.Lines[0].Date != "2019-03-31" || q.Lines[0].Quantity != 3 {
+		t.Fatalf("lines = %+v, want 3 hours of 2019-03-31", q.Lines)
+	}
+	if q.Total != 30 {
+		t.Errorf("total = %d, want 30", q.Total)
+	}
+
+	// the hours after 23:00 UTC are on the next local day
+	start = time.Date(2019, 1, 7, 22, 0, 0, 0, time.UTC)
+	end = time.Date(2019, 1, 8, 0, 0, 0, 0, time.UTC)
+	q = p.price(start, end, paris)
+	if len(q.Lines) != 2 || q.Lines[0].Date != "2019-01-07" || q.Lines[1].Date != "2019-01-08" {
+		t.Errorf("lines = %+v, want an hour of 2019-01-07 and one of 2019-01-08", q.Lines)
+	}
+}
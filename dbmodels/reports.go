@@ -0,0 +1,393 @@
+package dbmodels
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Groupings of the reports
+const (
+	ReportByRoom  = "room"
+	ReportByHotel = "hotel"
+)
+
+// Lengths of the periods of the occupancy reports
+const (
+	ReportDaily   = "day"
+	ReportWeekly  = "week"
+	ReportMonthly = "month"
+)
+
+// MaxReportDays is the longest period a report covers
+const MaxReportDays = 366
+
+// reportDateFormat is the format of the local dates of the reports
+const reportDateFormat = "2006-01-02"
+
+// occupiedStates are the states in which a booking counts in the occupancy reports
+var occupiedStates = []string{"booked", "completed"}
+
+// leadTimeBounds are the bounds in days of the lead time distribution
+var leadTimeBounds = []float64{1, 7, 30, 90}
+
+// span is a [Start, End) period
+type span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// roomPeriod is the booked time of a room during a period of an occupancy report
+type roomPeriod struct {
+	RoomID          uuid.UUID `db:"room_id"`
+	HotelID         uuid.UUID `db:"hotel_id"`
+	TimeZone        string    `db:"time_zone"`
+	AvailableFrom   string    `db:"available_from"`
+	AvailableTo     string    `db:"available_to"`
+	Capacity        int       `db:"capacity"`
+	Period          time.Time `db:"period"`
+	PeriodStart     time.Time `db:"period_start"`
+	PeriodEnd       time.Time `db:"period_end"`
+	Bookings        int       `db:"bookings"`
+	OccupiedSeconds float64   `db:"occupied_seconds"`
+}
+
+func validateReportQuery(q *ReportQuery) error {
+	if q.GroupBy == "" {
+		q.GroupBy = ReportByHotel
+	}
+	if q.GroupBy != ReportByRoom && q.GroupBy != ReportByHotel {
+		return fmt.Errorf("group_by must be %q or %q", ReportByRoom, ReportByHotel)
+	}
+	if q.Interval == "" {
+		q.Interval = ReportDaily
+	}
+	if q.Interval != ReportDaily && q.Interval != ReportWeekly && q.Interval != ReportMonthly {
+		return fmt.Errorf("interval must be %q, %q or %q", ReportDaily, ReportWeekly, ReportMonthly)
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if q.To.Sub(q.From) > MaxReportDays*24*time.Hour {
+		return fmt.Errorf("reports are limited to %d days", MaxReportDays)
+	}
+	return nil
+}
+
+// reportBookings returns the conditions selecting the bookings of the provider requested in the
+// local dates of the report, with their arguments
+func reportBookings(providerID uuid.UUID, q *ReportQuery) (string, []interface{}) {
+	where := `r.provider = $1 AND b.requested_at >= ($2::date::timestamp AT TIME ZONE h.time_zone)
+		AND b.requested_at < ($3::date::timestamp AT TIME ZONE h.time_zone)`
+	args := []interface{}{providerID, q.From.Format(reportDateFormat), q.To.Format(reportDateFormat)}
+	if q.HotelID != nil {
+		args = append(args, *q.HotelID)
+		where += fmt.Sprintf(` AND r.hotel_id = $%d`, len(args))
+	}
+	return where, args
+}
+
+// reportGroup returns the room column and the grouping of a report
+func reportGroup(q *ReportQuery) (string, string) {
+	if q.GroupBy == ReportByRoom {
+		return `r.id AS room_id`, `r.hotel_id, r.id`
+	}
+	return `NULL::uuid AS room_id`, `r.hotel_id`
+}
+
+// GetOccupancyReport returns per period the occupancy of the rooms of the provider, per room or
+// per hotel. The booked time is summed in the database, the opening hours and the blocks are
+// expanded afterwards in the time zone of the hotels.
+func GetOccupancyReport(providerID uuid.UUID, q ReportQuery) ([]OccupancyReportRow, int, error) {
+	if err := validateReportQuery(&q); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	args := []interface{}{providerID, q.From.Format(reportDateFormat), q.To.Format(reportDateFormat),
+		"1 " + q.Interval, q.Interval, pq.Array(occupiedStates)}
+	where := `r.provider = $1`
+	if q.HotelID != nil {
+		args = append(args, *q.HotelID)
+		where += fmt.Sprintf(` AND r.hotel_id = $%d`, len(args))
+	}
+	var periods []roomPeriod
+	err := database.Select(&periods, `
+		WITH report_rooms AS (
+			SELECT r.id, r.hotel_id, h.time_zone,
+				to_char(r.available_from, 'HH24:MI:SS') AS available_from,
+				to_char(r.available_to, 'HH24:MI:SS') AS available_to,
+				CASE WHEN r.is_shared THEN GREATEST(COALESCE(r.shared_nr_person, 1), 1) ELSE 1 END AS capacity
+			FROM rooms r JOIN hotels h ON h.id = r.hotel_id
+			WHERE `+where+`),
+		periods AS (
+			SELECT rm.*, p.local_start,
+				p.local_start AT TIME ZONE rm.time_zone AS period_start,
+				p.local_end AT TIME ZONE rm.time_zone AS period_end
+			FROM report_rooms rm CROSS JOIN LATERAL (
+				SELECT GREATEST(d, $2::date::timestamp) AS local_start,
+					LEAST(d + $4::interval, $3::date::timestamp) AS local_end
+				FROM generate_series(date_trunc($5, $2::date::timestamp), $3::date::timestamp - interval '1 second',
+					$4::interval) d) p)
+		SELECT p.id AS room_id, p.hotel_id, p.time_zone, p.available_from, p.available_to, p.capacity,
+			p.local_start::date AS period, p.period_start, p.period_end, count(b.id) AS bookings,
+			COALESCE(sum(extract(epoch FROM LEAST(b.end_time, p.period_end) - GREATEST(b.start_time, p.period_start))
+				* LEAST(COALESCE(b.party_size, 1), p.capacity)), 0) AS occupied_seconds
+		FROM periods p
+			LEFT JOIN bookings b ON b.room_id = p.id AND b.start_time < p.period_end AND b.end_time > p.period_start
+				AND b.state::text = ANY($6)
+		GROUP BY p.id, p.hotel_id, p.time_zone, p.available_from, p.available_to, p.capacity, p.local_start,
+			p.period_start, p.period_end
+		ORDER BY p.hotel_id, p.id, p.local_start`, args...)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	rows := []OccupancyReportRow{}
+	capacitySeconds := []float64{}
+	occupiedSeconds := []float64{}
+	index := map[string]int{}
+	for first := 0; first < len(periods); {
+		last := first
+		for last+1 < len(periods) && periods[last+1].RoomID == periods[first].RoomID {
+			last++
+		}
+		blocks, err := roomBlockPeriods(database, periods[first].RoomID, periods[first].PeriodStart, periods[last].PeriodEnd)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		blocked := mergeBlockPeriods(blocks)
+
+		for _, p := range periods[first : last+1] {
+			windows, err := openWindows(p.AvailableFrom, p.AvailableTo, p.TimeZone, p.PeriodStart, p.PeriodEnd)
+			if err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+			var open time.Duration
+			for _, w := range windows {
+				open += w.End.Sub(w.Start)
+			}
+			closed := overlap(windows, blocked)
+
+			key := p.HotelID.String() + p.Period.Format(reportDateFormat)
+			if q.GroupBy == ReportByRoom {
+				key = p.RoomID.String() + p.Period.Format(reportDateFormat)
+			}
+			i, ok := index[key]
+			if !ok {
+				i = len(rows)
+				index[key] = i
+				row := OccupancyReportRow{Period: p.Period.Format(reportDateFormat), HotelID: p.HotelID}
+				if q.GroupBy == ReportByRoom {
+					roomID := p.RoomID
+					row.RoomID = &roomID
+				}
+				rows = append(rows, row)
+				capacitySeconds = append(capacitySeconds, 0)
+				occupiedSeconds = append(occupiedSeconds, 0)
+			}
+			row := &rows[i]
+			row.Rooms++
+			row.Bookings += p.Bookings
+			row.OpenSeconds += int64(open / time.Second)
+			row.BlockedSeconds += int64(closed / time.Second)
+			row.BookableSeconds += int64((open - closed) / time.Second)
+			capacitySeconds[i] += (open - closed).Seconds() * float64(p.Capacity)
+			occupiedSeconds[i] += p.OccupiedSeconds
+		}
+		first = last + 1
+	}
+	for i := range rows {
+		rows[i].OccupiedSeconds = int64(math.Round(occupiedSeconds[i]))
+		if capacitySeconds[i] > 0 {
+			rows[i].Occupancy = occupiedSeconds[i] / capacitySeconds[i]
+		}
+	}
+	return rows, http.StatusOK, nil
+}
+
+// openWindows returns the opening hours, given as local times of the time zone, within the
+// [start, end) period
+func openWindows(availableFrom, availableTo, timeZone string, start, end time.Time) ([]span, error) {
+	if availableFrom == availableTo {
+		return []span{{Start: start, End: end}}, nil
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+	from, err := time.Parse(clockFormat, availableFrom)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(clockFormat, availableTo)
+	if err != nil {
+		return nil, err
+	}
+
+	local := start.In(loc)
+	// an overnight opening may have started the day before
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	windows := []span{}
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		opens := atClock(day, from, loc)
+		closes := atClock(day, to, loc)
+		if !to.After(from) {
+			closes = atClock(day.AddDate(0, 0, 1), to, loc)
+		}
+		if opens.Before(start) {
+			opens = start
+		}
+		if closes.After(end) {
+			closes = end
+		}
+		if opens.Before(closes) {
+			windows = append(windows, span{Start: opens, End: closes})
+		}
+	}
+	return windows, nil
+}
+
+// mergeBlockPeriods merges the overlapping periods, sorted by their start, of the blocks
+func mergeBlockPeriods(periods []blockPeriod) []span {
+	merged := []span{}
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.StartTime.After(merged[n-1].End) {
+			if p.EndTime.After(merged[n-1].End) {
+				merged[n-1].End = p.EndTime
+			}
+			continue
+		}
+		merged = append(merged, span{Start: p.StartTime, End: p.EndTime})
+	}
+	return merged
+}
+
+// overlap returns the time both sorted lists of disjoint spans cover
+func overlap(a, b []span) time.Duration {
+	var d time.Duration
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := a[i].Start, a[i].End
+		if b[j].Start.After(start) {
+			start = b[j].Start
+		}
+		if b[j].End.Before(end) {
+			end = b[j].End
+		}
+		if start.Before(end) {
+			d += end.Sub(start)
+		}
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return d
+}
+
+// GetBookingStatsReport counts by state the booking requests of the provider requested in the
+// period, per room or per hotel, with their cancellation rate and lead times. The cancellation
+// rate is the part of the booked requests which were cancelled.
+func GetBookingStatsReport(providerID uuid.UUID, q ReportQuery) ([]BookingStatsReportRow, int, error) {
+	if err := validateReportQuery(&q); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	where, args := reportBookings(providerID, &q)
+	roomColumn, groupBy := reportGroup(&q)
+	rows := []BookingStatsReportRow{}
+	err := database.Select(&rows, `
+		SELECT r.hotel_id, `+roomColumn+`, count(*) AS total,
+			count(*) FILTER (WHERE b.state = 'draft') AS draft,
+			count(*) FILTER (WHERE b.state = 'pending') AS pending,
+			count(*) FILTER (WHERE b.state = 'pending_resp') AS pending_resp,
+			count(*) FILTER (WHERE b.state = 'booked') AS booked,
+			count(*) FILTER (WHERE b.state = 'completed') AS completed,
+			count(*) FILTER (WHERE b.state = 'rejected') AS rejected,
+			count(*) FILTER (WHERE b.state = 'cancelled') AS cancelled,
+			COALESCE(avg(extract(epoch FROM b.start_time - b.requested_at))
+				FILTER (WHERE b.state <> 'draft'), 0) AS average_lead_seconds,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY extract(epoch FROM b.start_time - b.requested_at))
+				FILTER (WHERE b.state <> 'draft'), 0) AS median_lead_seconds,
+			COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY extract(epoch FROM b.start_time - b.requested_at))
+				FILTER (WHERE b.state <> 'draft'), 0) AS p90_lead_seconds
+		FROM bookings b JOIN rooms r ON r.id = b.room_id JOIN hotels h ON h.id = r.hotel_id
+		WHERE `+where+`
+		GROUP BY `+groupBy+`
+		ORDER BY `+groupBy, args...)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	for i := range rows {
+		if booked := rows[i].Booked + rows[i].Completed + rows[i].Cancelled; booked > 0 {
+			rows[i].CancellationRate = float64(rows[i].Cancelled) / float64(booked)
+		}
+	}
+	return rows, http.StatusOK, nil
+}
+
+// GetLeadTimeReport returns the distribution of the lead times, from the request to the start,
+// of the booking requests of the provider requested in the period, per room or per hotel. The
+// drafts aren't requests yet.
+func GetLeadTimeReport(providerID uuid.UUID, q ReportQuery) ([]LeadTimeReportRow, int, error) {
+	if err := validateReportQuery(&q); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	where, args := reportBookings(providerID, &q)
+	roomColumn, groupBy := reportGroup(&q)
+	args = append(args, pq.Array(leadTimeBounds))
+	var counts []struct {
+		LeadTimeReportRow
+		Bucket int `db:"bucket"`
+	}
+	err := database.Select(&counts, fmt.Sprintf(`
+		SELECT r.hotel_id, `+roomColumn+`,
+			width_bucket(extract(epoch FROM b.start_time - b.requested_at) / 86400, $%d::float8[]) AS bucket,
+			count(*) AS bookings
+		FROM bookings b JOIN rooms r ON r.id = b.room_id JOIN hotels h ON h.id = r.hotel_id
+		WHERE `+where+` AND b.state <> 'draft'
+		GROUP BY `+groupBy+`, bucket
+		ORDER BY `+groupBy+`, bucket`, len(args)), args...)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	rows := []LeadTimeReportRow{}
+	totals := []int{}
+	first := -1
+	for _, c := range counts {
+		if first < 0 || rows[first].HotelID != c.HotelID || groupRoom(rows[first].RoomID) != groupRoom(c.RoomID) {
+			first = len(rows)
+			for i := 0; i <= len(leadTimeBounds); i++ {
+				row := LeadTimeReportRow{HotelID: c.HotelID, RoomID: c.RoomID}
+				if i > 0 {
+					row.MinDays = int(leadTimeBounds[i-1])
+				}
+				if i < len(leadTimeBounds) {
+					maxDays := int(leadTimeBounds[i])
+					row.MaxDays = &maxDays
+				}
+				rows = append(rows, row)
+			}
+			totals = append(totals, 0)
+		}
+		rows[first+c.Bucket].Bookings = c.Bookings
+		totals[len(totals)-1] += c.Bookings
+	}
+	for i := range rows {
+		if total := totals[i/(len(leadTimeBounds)+1)]; total > 0 {
+			rows[i].Share = float64(rows[i].Bookings) / float64(total)
+		}
+	}
+	return rows, http.StatusOK, nil
+}
+
+// groupRoom returns the room of a report group, the nil UUID for the hotel groups
+func groupRoom(roomID *uuid.UUID) uuid.UUID {
+	if roomID == nil {
+		return uuid.Nil
+	}
+	return *roomID
+}
@@ -0,0 +1,199 @@
+package dbmodels
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies supported in RRULE
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// MaxSeriesOccurrences limits the number of bookings a recurrence rule may expand to
+const MaxSeriesOccurrences = 366
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule is the subset of the iCalendar (RFC 5545) recurrence rule used for booking series
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []time.Weekday
+}
+
+// ParseRRule parses a recurrence rule like "FREQ=WEEKLY;BYDAY=MO;COUNT=10"
+func ParseRRule(rule string) (*RRule, error) {
+	r := &RRule{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rrule part %q", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			if value != FreqDaily && value != FreqWeekly && value != FreqMonthly {
+				return nil, fmt.Errorf("unsupported rrule frequency %q", value)
+			}
+			r.Freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid rrule interval %q", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid rrule count %q", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := parseICalTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rrule until %q", value)
+			}
+			r.Until = &t
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := rruleWeekdays[d]
+				if !ok {
+					return nil, fmt.Errorf("invalid rrule weekday %q", d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", key)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rrule FREQ is mandatory")
+	}
+	if r.Count == 0 && r.Until == nil {
+		return nil, fmt.Errorf("rrule needs COUNT or UNTIL")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("rrule COUNT and UNTIL are mutually exclusive")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqWeekly {
+		return nil, fmt.Errorf("rrule BYDAY is only supported with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+func parseICalTime(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return t, err
+	}
+	// a date only UNTIL includes the whole day
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// Expand returns the start times of the occurrences beginning with start, the
+// exdates are left out. The occurrences are at the clock time of start in its location.
+func (r *RRule) Expand(start time.Time, exdates []time.Time) ([]time.Time, error) {
+	excluded := func(t time.Time) bool {
+		for _, ex := range exdates {
+			if ex.Equal(t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	res := []time.Time{}
+	generated := 0
+	// emit returns false when the expansion is finished
+	emit := func(t time.Time) (bool, error) {
+		if r.Until != nil && t.After(*r.Until) {
+			return false, nil
+		}
+		generated++
+		if generated > MaxSeriesOccurrences {
+			return false, fmt.Errorf("the rrule expands to more than %d occurrences", MaxSeriesOccurrences)
+		}
+		if !excluded(t) {
+			res = append(res, t)
+		}
+		return r.Count == 0 || generated < r.Count, nil
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+		for k := 0; ; k++ {
+			more, err := emit(start.AddDate(0, 0, k*r.Interval))
+			if err != nil || !more {
+				return res, err
+			}
+		}
+	case FreqWeekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		offsets := make([]int, 0, len(days))
+		for _, d := range days {
+			offsets = append(offsets, (int(d)+6)%7) // days since monday
+		}
+		sort.Ints(offsets)
+		weekStart := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		for k := 0; ; k++ {
+			week := weekStart.AddDate(0, 0, 7*k*r.Interval)
+			for _, off := range offsets {
+				t := week.AddDate(0, 0, off)
+				if t.Before(start) {
+					continue
+				}
+				more, err := emit(t)
+				if err != nil || !more {
+					return res, err
+				}
+			}
+		}
+	case FreqMonthly:
+		for k := 0; ; k++ {
+			t := time.Date(start.Year(), start.Month()+time.Month(k*r.Interval), start.Day(),
+				start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+			if t.Day() != start.Day() {
+				// the day does not exist in this month, e.g. the 31st
+				if k > 12*MaxSeriesOccurrences {
+					return res, nil
+				}
+				continue
+			}
+			more, err := emit(t)
+			if err != nil || !more {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,182 @@
+package dbmodels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	r, err := ParseRRule("RRULE:FREQ=weekly;byday=mo,fr;interval=2;count=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Freq != FreqWeekly || r.Interval != 2 || r.Count != 10 || len(r.ByDay) != 2 ||
+		r.ByDay[0] != time.Monday || r.ByDay[1] != time.Friday {
+		t.Errorf("parsed %+v", r)
+	}
+
+	r, err = ParseRRule("FREQ=DAILY;UNTIL=20190115")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a date only UNTIL includes the whole day
+	if want := time.Date(2019, 1, 15, 23, 59, 59, 0, time.UTC); r.Until == nil || !r.Until.Equal(want) {
+		t.Errorf("until = %v, want %v", r.Until, want)
+	}
+	r, err = ParseRRule("FREQ=DAILY;UNTIL=20190115T100000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2019, 1, 15, 10, 0, 0, 0, time.UTC); r.Until == nil || !r.Until.Equal(want) {
+		t.Errorf("until = %v, want %v", r.Until, want)
+	}
+
+	for _, rule := range []string{
+		"",
+		"COUNT=3",
+		"FREQ=YEARLY;COUNT=3",
+		"FREQ=DAILY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20190115",
+		"FREQ=DAILY;INTERVAL=0;COUNT=3",
+		"FREQ=DAILY;BYDAY=MO;COUNT=3",
+		"FREQ=WEEKLY;BYDAY=XX;COUNT=3",
+		"FREQ=WEEKLY;WKST=SU;COUNT=3",
+		"FREQ=DAILY;UNTIL=2019-01-15",
+		"FREQ=DAILY;BYMONTH=1;COUNT=3",
+		"FREQ=DAILY;COUNT",
+	} {
+		if _, err := ParseRRule(rule); err == nil {
+			t.Errorf("ParseRRule(%q) accepted an invalid rule", rule)
+		}
+	}
+}
+
+func TestRRuleExpand(t *testing.T) {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name    string
+		rule    string
+		start   time.Time
+		exdates []time.Time
+		want    []time.Time
+	}{
+		{
+			name:  "daily",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: at(2019, 1, 10),
+			want:  []time.Time{at(2019, 1, 10), at(2019, 1, 11), at(2019, 1, 12)},
+		},
+		{
+			name:  "daily until a date",
+			rule:  "FREQ=DAILY;INTERVAL=2;UNTIL=20190115",
+			start: at(2019, 1, 11),
+			want:  []time.Time{at(2019, 1, 11), at(2019, 1, 13), at(2019, 1, 15)},
+		},
+		{
+			name:  "daily until a time",
+			rule:  "FREQ=DAILY;UNTIL=20190112T095959Z",
+			start: at(2019, 1, 10),
+			want:  []time.Time{at(2019, 1, 10), at(2019, 1, 11)},
+		},
+		{
+			name:  "weekly by day from the middle of the week",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5",
+			start: at(2019, 1, 9),
+			want:  []time.Time{at(2019, 1, 9), at(2019, 1, 11), at(2019, 1, 14), at(2019, 1, 16), at(2019, 1, 18)},
+		},
+		{
+			name:  "every other week",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			start: at(2019, 1, 8),
+			want:  []time.Time{at(2019, 1, 8), at(2019, 1, 22), at(2019, 2, 5)},
+		},
+		{
+			name:  "monthly on the 31st skips the shorter months",
+			rule:  "FREQ=MONTHLY;COUNT=4",
+			start: at(2019, 1, 31),
+			want:  []time.Time{at(2019, 1, 31), at(2019, 3, 31), at(2019, 5, 31), at(2019, 7, 31)},
+		},
+		{
+			name:  "monthly on the 29th of February",
+			rule:  "FREQ=MONTHLY;INTERVAL=12;COUNT=2",
+			start: at(2016, 2, 29),
+			want:  []time.Time{at(2016, 2, 29), at(2020, 2, 29)},
+		},
+		{
+			name:    "exdates count as occurrences",
+			rule:    "FREQ=DAILY;COUNT=3",
+			start:   at(2019, 1, 10),
+			exdates: []time.Time{at(2019, 1, 11)},
+			want:    []time.Time{at(2019, 1, 10), at(2019, 1, 12)},
+		},
+	}
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := r.Expand(tt.start, tt.exdates)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: occurrence %d = %v, want %v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestRRuleExpandCap(t *testing.T) {
+	start := time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	r, _ := ParseRRule("FREQ=DAILY;COUNT=366")
+	got, err := r.Expand(start, nil)
+	if err != nil || len(got) != MaxSeriesOccurrences {
+		t.Errorf("COUNT=366 expanded to %d occurrences, %v", len(got), err)
+	}
+	for _, rule := range []string{"FREQ=DAILY;COUNT=367", "FREQ=DAILY;UNTIL=20200102"} {
+		r, _ := ParseRRule(rule)
+		if _, err := r.Expand(start, nil); err == nil {
+			t.Errorf("%s: expected the occurrences cap error", rule)
+		}
+	}
+	// the excluded occurrences count towards the cap
+	r, _ = ParseRRule("FREQ=DAILY;UNTIL=20200101")
+	if _, err := r.Expand(start, []time.Time{start}); err != nil {
+		t.Errorf("366 occurrences with an exdate: %v", err)
+	}
+}
+
+func TestRRuleExpandKeepsTheLocalTimeAcrossDST(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	// the start is posted with the offset of the winter time
+	start := time.Date(2019, 3, 25, 10, 0, 0, 0, time.FixedZone("", 3600))
+
+	r, _ := ParseRRule("FREQ=WEEKLY;COUNT=3")
+	got, err := r.Expand(start.In(paris), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2019, 3, 25, 9, 0, 0, 0, time.UTC),
+		time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC),
+		time.Date(2019, 4, 8, 8, 0, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if !got[i].Equal(want[i]) || got[i].In(paris).Hour() != 10 {
+			t.Errorf("occurrence %d = %v, want 10:00 local at %v", i, got[i], want[i])
+		}
+	}
+}
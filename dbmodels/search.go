@@ -0,0 +1,120 @@
+package dbmodels
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxRoomSearchResults is the largest number of rooms a search returns
+const MaxRoomSearchResults = 100
+
+// SearchRooms returns the rooms open, not blocked and with room left for the party in the
+// searched period, the ones wasting the least capacity come first. A room which isn't shared
+// takes a party of any size when no booking occupies it. The occupancy is computed in the
+// database, the recurring blocks are expanded afterwards.
+func SearchRooms(search RoomSearch) ([]RoomSearchResult, int, error) {
+	if search.StartTime.IsZero() || search.EndTime.IsZero() {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time and end_time are mandatory")
+	}
+	if !search.StartTime.Before(search.EndTime) {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time must be before end_time")
+	}
+	if search.PartySize == 0 {
+		search.PartySize = 1
+	}
+	if search.PartySize < 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("party_size must be positive")
+	}
+	if search.Limit <= 0 || search.Limit > MaxRoomSearchResults {
+		search.Limit = MaxRoomSearchResults
+	}
+
+	args := []interface{}{search.StartTime, search.EndTime, search.PartySize, pq.Array(blockingStates),
+		time.Now().UTC()}
+	conds := []string{"TRUE"}
+	add := func(cond string, value interface{}) {
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if search.HotelID != nil {
+		add(`r.hotel_id = $%d`, *search.HotelID)
+	}
+	if len(search.DataCenters) > 0 {
+		add(`h.data_center_id::text = ANY($%d)`, pq.Array(uuidStrings(search.DataCenters)))
+	}
+	if search.Type != "" {
+		add(`r.type::text = $%d`, search.Type)
+	}
+
+	// the occupancy only grows when a booking starts, its peak is reached at one of the starts
+	occupying := func(alias string) string {
+		return fmt.Sprintf(`(%[1]s.state::text = ANY($4) OR (%[1]s.state = 'draft' AND %[1]s.expires_at > $5))`, alias)
+	}
+	var candidates []struct {
+		RoomSearchResult
+		RecurringBlocks bool `db:"recurring_blocks"`
+	}
+	err := database.Select(&candidates, `
+		SELECT r.id, r.name, r.hotel_id, COALESCE(r.type::text, '') AS type, r.reservation_max_time,
+			to_char(r.available_from, 'HH24:MI:SS') AS available_from,
+			to_char(r.available_to, 'HH24:MI:SS') AS available_to,
+			r.reservation_lead_time, r.is_shared, r.shared_nr_person, r.description,
+			h.name AS hotel_name, h.data_center_id, h.time_zone, c.capacity,
+			c.capacity - COALESCE(occ.peak, 0) AS remaining,
+			EXISTS (
+				SELECT 1 FROM room_blocks b
+				WHERE b.hotel_id = r.hotel_id AND (b.room_id = r.id OR (b.room_id IS NULL AND b.provider_id = r.provider))
+					AND b.rrule IS NOT NULL AND b.start_time < $2) AS recurring_blocks
+		FROM rooms r
+			JOIN hotels h ON h.id = r.hotel_id
+			CROSS JOIN LATERAL (SELECT CASE WHEN r.is_shared THEN GREATEST(COALESCE(r.shared_nr_person, 1), 1)
+				ELSE 1 END AS capacity) c
+			LEFT JOIN LATERAL (
+				SELECT max(points.occupied) AS peak FROM (
+					SELECT sum(o.party_size) AS occupied
+					FROM bookings s JOIN bookings o ON o.room_id = s.room_id
+						AND o.start_time <= GREATEST(s.start_time, $1) AND o.end_time > GREATEST(s.start_time, $1)
+					WHERE s.room_id = r.id AND s.start_time < $2 AND s.end_time > $1
+						AND `+occupying("s")+` AND `+occupying("o")+`
+					GROUP BY s.id) points
+			) occ ON TRUE
+		WHERE `+strings.Join(conds, " AND ")+`
+			AND (CASE WHEN r.is_shared THEN c.capacity - COALESCE(occ.peak, 0) >= $3
+				ELSE occ.peak IS NULL END)
+			AND NOT EXISTS (
+				SELECT 1 FROM room_blocks b
+				WHERE b.hotel_id = r.hotel_id AND (b.room_id = r.id OR (b.room_id IS NULL AND b.provider_id = r.provider))
+					AND b.rrule IS NULL AND b.start_time < $2 AND b.end_time > $1)
+		ORDER BY GREATEST(c.capacity - $3, 0), remaining DESC, h.name, r.name`, args...)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	rooms := []RoomSearchResult{}
+	for _, room := range candidates {
+		open, err := isOpen(room.AvailableFrom, room.AvailableTo, room.TimeZone, search.StartTime, search.EndTime)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if !open {
+			continue
+		}
+		if room.RecurringBlocks {
+			blocks, err := roomBlockPeriods(database, room.ID, search.StartTime, search.EndTime)
+			if err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+			if len(blocks) > 0 {
+				continue
+			}
+		}
+		if rooms = append(rooms, room.RoomSearchResult); len(rooms) == search.Limit {
+			break
+		}
+	}
+	return rooms, http.StatusOK, nil
+}
@@ -0,0 +1,164 @@
+package dbmodels
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Scopes of a change on a booking belonging to a series
+const (
+	ScopeOccurrence = "occurrence"
+	ScopeFollowing  = "following"
+	ScopeSeries     = "series"
+)
+
+// postBookingSeries expands the recurrence rule of the body and creates a booking per
+// occurrence, all of them are checked for conflicts before anything is committed. The rule
+// is expanded in the time zone of the hotel, the occurrences keep their local time across
+// the DST transitions.
+func postBookingSeries(tx *txn, body *BookingPost) (*Booking, int, error) {
+	rule, err := ParseRRule(*body.RRule)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	loc, state, err := roomLocation(tx, body.RoomID)
+	if err != nil {
+		return nil, state, err
+	}
+	starts, err := rule.Expand(body.StartTime.In(loc), body.ExDates)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(starts) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("the rrule has no occurrences")
+	}
+
+	seriesID := uuid.NewV4()
+	exdates := make([]string, 0, len(body.ExDates))
+	for _, ex := range body.ExDates {
+		exdates = append(exdates, ex.UTC().Format("2006-01-02 15:04:05"))
+	}
+	_, err = tx.Exec(`INSERT INTO booking_series (id, rrule, exdates) VALUES ($1, $2, $3)`,
+		seriesID, *body.RRule, pq.Array(exdates))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	duration := body.EndTime.Sub(body.StartTime)
+	occurrences := make([]Booking, 0, len(starts))
+	for _, start := range starts {
+		occurrence := *body
+		occurrence.RRule = nil
+		occurrence.ExDates = nil
+		occurrence.StartTime = start
+		occurrence.EndTime = start.Add(duration)
+		b, state, err := insertBooking(tx, &occurrence, &seriesID)
+		if err != nil {
+			return nil, state, fmt.Errorf("occurrence %s: %s", start.Format(time.RFC3339), err)
+		}
+		occurrences = append(occurrences, *b)
+	}
+
+	res := occurrences[0]
+	res.Occurrences = occurrences
+	return &res, http.StatusOK, nil
+}
+
+// PatchBookingScope applies the patch to the booking, to the booking and the following
+// occurrences of its series or to the whole series. Start and end times of the other
+// occurrences are shifted by the same amount as the ones of the given booking.
+func PatchBookingScope(body *BookingPatch, id uuid.UUID, scope, role string, ownerID uuid.UUID) (*Booking, int, error) {
+	if scope == "" || scope == ScopeOccurrence {
+		return PatchBooking(body, id, role, ownerID)
+	}
+	if scope != ScopeFollowing && scope != ScopeSeries {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid scope %q", scope)
+	}
+
+	var res *Booking
+	state, err := inTx(func(tx *txn) (int, error) {
+		current, state, err := bookingAccess(tx, id, role, ownerID, true)
+		if err != nil {
+			return state, err
+		}
+		if current.SeriesID == nil {
+			var state int
+			res, state, err = patchBooking(tx, body, id)
+			return state, err
+		}
+
+		targets := []Booking{}
+		if scope == ScopeFollowing {
+			err = tx.Select(&targets, `SELECT `+bookingColumns+` FROM bookings
+				WHERE series_id = $1 AND start_time >= $2 ORDER BY start_time FOR UPDATE`,
+				*current.SeriesID, current.StartTime)
+		} else {
+			err = tx.Select(&targets, `SELECT `+bookingColumns+` FROM bookings
+				WHERE series_id = $1 ORDER BY start_time FOR UPDATE`, *current.SeriesID)
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		for _, p := range seriesPatches(body, current, targets) {
+			b, state, err := patchBooking(tx, &p.patch, p.target.ID, p.exclude...)
+			if err != nil {
+				return state, fmt.Errorf("occurrence %s: %s", p.target.StartTime.Format(time.RFC3339), err)
+			}
+			if b.ID == id {
+				res = b
+			}
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	if err := localizeBooking(res); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return res, http.StatusOK, nil
+}
+
+// seriesPatch is the change of an occurrence of a series patched with a scope
+type seriesPatch struct {
+	target  *Booking
+	patch   BookingPatch
+	exclude []uuid.UUID
+}
+
+// seriesPatches shifts the times of the targets by the change of the times of the current
+// booking. The targets are rewritten in order, the conflict check of each one leaves out the
+// slots of the targets not rewritten yet, which are freed, but not the new slots of the
+// targets rewritten before it.
+func seriesPatches(body *BookingPatch, current *Booking, targets []Booking) []seriesPatch {
+	var startShift, endShift time.Duration
+	if body.StartTime != nil {
+		startShift = body.StartTime.Sub(current.StartTime)
+	}
+	if body.EndTime != nil {
+		endShift = body.EndTime.Sub(current.EndTime)
+	}
+	res := make([]seriesPatch, 0, len(targets))
+	for i := range targets {
+		t := &targets[i]
+		p := seriesPatch{target: t, patch: *body}
+		if body.StartTime != nil {
+			start := t.StartTime.Add(startShift)
+			p.patch.StartTime = &start
+		}
+		if body.EndTime != nil {
+			end := t.EndTime.Add(endShift)
+			p.patch.EndTime = &end
+		}
+		for _, pending := range targets[i+1:] {
+			p.exclude = append(p.exclude, pending.ID)
+		}
+		res = append(res, p)
+	}
+	return res
+}
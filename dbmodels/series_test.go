@@ -0,0 +1,55 @@
+package dbmodels
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestSeriesPatches(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2019, 1, d, hour, 0, 0, 0, time.UTC)
+	}
+	targets := []Booking{
+		{ID: uuid.NewV4(), StartTime: day(10, 10), EndTime: day(10, 12)},
+		{ID: uuid.NewV4(), StartTime: day(11, 10), EndTime: day(11, 12)},
+		{ID: uuid.NewV4(), StartTime: day(12, 10), EndTime: day(12, 12)},
+	}
+	// the current occurrence is extended by a day, the new slots overlap each other
+	end := day(11, 12)
+	patches := seriesPatches(&BookingPatch{EndTime: &end}, &targets[0], targets)
+	if len(patches) != len(targets) {
+		t.Fatalf("%d patches for %d targets", len(patches), len(targets))
+	}
+	for i, p := range patches {
+		if p.target.ID != targets[i].ID {
+			t.Errorf("patch %d targets %s, want %s", i, p.target.ID, targets[i].ID)
+		}
+		if p.patch.StartTime != nil {
+			t.Errorf("patch %d moves the start time", i)
+		}
+		if want := targets[i].EndTime.Add(24 * time.Hour); p.patch.EndTime == nil || !p.patch.EndTime.Equal(want) {
+			t.Errorf("patch %d ends at %v, want %v", i, p.patch.EndTime, want)
+		}
+		// the slots rewritten before are checked, the ones rewritten after are freed
+		excluded := map[uuid.UUID]bool{}
+		for _, id := range p.exclude {
+			excluded[id] = true
+		}
+		for j := range targets {
+			if want := j > i; excluded[targets[j].ID] != want {
+				t.Errorf("patch %d excludes occurrence %d: %v, want %v", i, j, excluded[targets[j].ID], want)
+			}
+		}
+	}
+
+	start, end := day(11, 9), day(11, 11)
+	patches = seriesPatches(&BookingPatch{StartTime: &start, EndTime: &end}, &targets[1], targets)
+	if got := *patches[0].patch.StartTime; !got.Equal(day(10, 9)) {
+		t.Errorf("the first occurrence starts at %v, want %v", got, day(10, 9))
+	}
+	if got := *patches[2].patch.EndTime; !got.Equal(day(12, 11)) {
+		t.Errorf("the last occurrence ends at %v, want %v", got, day(12, 11))
+	}
+}
@@ -0,0 +1,213 @@
+package dbmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// clockFormat is the format of the opening hours of the rooms
+const clockFormat = "15:04:05"
+
+// PatchHotel updates a hotel, its time zone must be an IANA one like Europe/Budapest
+func PatchHotel(id uuid.UUID, body *HotelPatch) (*Hotel, int, error) {
+	if body.TimeZone == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("time_zone is mandatory")
+	}
+	if _, err := time.LoadLocation(*body.TimeZone); err != nil || *body.TimeZone == "" || *body.TimeZone == "Local" {
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown time zone %q", *body.TimeZone)
+	}
+	var h Hotel
+	err := database.Get(&h, `UPDATE hotels SET time_zone = $2 WHERE id = $1
+		RETURNING id, name, data_center_id, description, time_zone`, id, *body.TimeZone)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("hotel %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &h, http.StatusOK, nil
+}
+
+// checkOpeningHours fails with 400 when the [start, end) period is outside the opening hours
+// of the room in the local time of its hotel, a room opening and closing at the same time is
+// always open and one closing before it opens is open overnight
+func checkOpeningHours(q sqlx.Queryer, roomID uuid.UUID, start, end time.Time) (int, error) {
+	var room struct {
+		AvailableFrom string `db:"available_from"`
+		AvailableTo   string `db:"available_to"`
+		TimeZone      string `db:"time_zone"`
+	}
+	err := sqlx.Get(q, &room, `
+		SELECT to_char(r.available_from, 'HH24:MI:SS') AS available_from,
+			to_char(r.available_to, 'HH24:MI:SS') AS available_to, COALESCE(h.time_zone, 'UTC') AS time_zone
+		FROM rooms r LEFT JOIN hotels h ON h.id = r.hotel_id
+		WHERE r.id = $1`, roomID)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("room %s not found", roomID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	open, err := isOpen(room.AvailableFrom, room.AvailableTo, room.TimeZone, start, end)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !open {
+		return http.StatusBadRequest, fmt.Errorf("room %s is open from %s to %s, %s time",
+			roomID, room.AvailableFrom, room.AvailableTo, room.TimeZone)
+	}
+	return http.StatusOK, nil
+}
+
+// isOpen tells whether the [start, end) period is within the opening hours, given as local
+// times of the time zone
+func isOpen(availableFrom, availableTo, timeZone string, start, end time.Time) (bool, error) {
+	if availableFrom == availableTo {
+		return true, nil
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return false, err
+	}
+	from, err := time.Parse(clockFormat, availableFrom)
+	if err != nil {
+		return false, err
+	}
+	to, err := time.Parse(clockFormat, availableTo)
+	if err != nil {
+		return false, err
+	}
+
+	// the day is only a date, the midnight of the location may be skipped by a DST transition
+	local := start.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	// an overnight opening may have started the day before
+	for _, d := range []time.Time{day.AddDate(0, 0, -1), day} {
+		opens := atClock(d, from, loc)
+		closes := atClock(d, to, loc)
+		if !to.After(from) {
+			closes = atClock(d.AddDate(0, 0, 1), to, loc)
+		}
+		if !start.Before(opens) && !end.After(closes) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// atClock returns the time of the day at the clock time in the location. A clock time
+// repeated by a DST transition is its first occurrence, a clock time skipped by a transition
+// is read with the offset before it, which moves it forward by the length of the gap.
+func atClock(day, clock time.Time, loc *time.Location) time.Time {
+	wall := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+	// the DST transitions are months apart, the offsets a day away are the ones around the day
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	t := wall.Add(-time.Duration(before) * time.Second).In(loc)
+	if u := wall.Add(-time.Duration(after) * time.Second).In(loc); onClock(u, clock) && (!onClock(t, clock) || u.Before(t)) {
+		t = u
+	}
+	return t
+}
+
+// onClock tells whether the local time of t is the clock time
+func onClock(t, clock time.Time) bool {
+	return t.Hour() == clock.Hour() && t.Minute() == clock.Minute() && t.Second() == clock.Second()
+}
+
+// roomLocation returns the time zone of the hotel of the room, UTC for a room without hotel
+func roomLocation(q sqlx.Queryer, roomID uuid.UUID) (*time.Location, int, error) {
+	var tz string
+	err := sqlx.Get(q, &tz, `
+		SELECT COALESCE(h.time_zone, 'UTC') FROM rooms r LEFT JOIN hotels h ON h.id = r.hotel_id
+		WHERE r.id = $1`, roomID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("room %s not found", roomID)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return loc, http.StatusOK, nil
+}
+
+// localize sets the times of the bookings and of their occurrences in UTC and in the time
+// zone of their hotel
+func localize(bookings []Booking) error {
+	all := append([]Booking{}, bookings...)
+	for _, b := range bookings {
+		all = append(all, b.Occurrences...)
+	}
+	zones, err := getTimeZonesByRoom(roomIDs(all))
+	if err != nil {
+		return err
+	}
+	set := func(b *Booking) error {
+		b.StartTime = b.StartTime.UTC()
+		b.EndTime = b.EndTime.UTC()
+		b.RequestedAt = b.RequestedAt.UTC()
+		tz, ok := zones[b.RoomID]
+		if !ok {
+			return nil
+		}
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return err
+		}
+		b.Local = &LocalTimes{TimeZone: tz, StartTime: b.StartTime.In(loc), EndTime: b.EndTime.In(loc)}
+		return nil
+	}
+	for i := range bookings {
+		if err := set(&bookings[i]); err != nil {
+			return err
+		}
+		for j := range bookings[i].Occurrences {
+			if err := set(&bookings[i].Occurrences[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getTimeZonesByRoom(ids []uuid.UUID) (map[uuid.UUID]string, error) {
+	res := map[uuid.UUID]string{}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT r.id, h.time_zone FROM rooms r JOIN hotels h ON h.id = r.hotel_id
+		WHERE r.id IN (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID       uuid.UUID `db:"id"`
+		TimeZone string    `db:"time_zone"`
+	}
+	if err = database.Select(&rows, database.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		res[r.ID] = r.TimeZone
+	}
+	return res, nil
+}
+
+// localizeBooking sets the times of the booking like localize
+func localizeBooking(b *Booking) error {
+	bookings := []Booking{*b}
+	if err := localize(bookings); err != nil {
+		return err
+	}
+	*b = bookings[0]
+	return nil
+}
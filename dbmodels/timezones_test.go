@@ -0,0 +1,88 @@
+package dbmodels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsOpen(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	local := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2019, month, day, hour, min, 0, 0, paris)
+	}
+	tests := []struct {
+		name       string
+		from, to   string
+		start, end time.Time
+		open       bool
+	}{
+		{"always open", "00:00:00", "00:00:00", local(3, 30, 3, 0), local(3, 31, 3, 0), true},
+		{"within the day", "08:00:00", "20:00:00", local(1, 10, 8, 0), local(1, 10, 20, 0), true},
+		{"before opening", "08:00:00", "20:00:00", local(1, 10, 7, 59), local(1, 10, 10, 0), false},
+		{"after closing", "08:00:00", "20:00:00", local(1, 10, 10, 0), local(1, 10, 20, 1), false},
+		{"across two days", "08:00:00", "20:00:00", local(1, 10, 10, 0), local(1, 11, 10, 0), false},
+		// 08:00 is 06:00 UTC after the clocks go forward, 07:00 UTC the day before
+		{"opening on the DST day", "08:00:00", "20:00:00", local(3, 31, 8, 0), local(3, 31, 20, 0), true},
+		{"before opening on the DST day", "08:00:00", "20:00:00",
+			time.Date(2019, 3, 31, 5, 30, 0, 0, time.UTC), time.Date(2019, 3, 31, 7, 0, 0, 0, time.UTC), false},
+		{"opening after the DST day", "08:00:00", "20:00:00", local(10, 27, 8, 0), local(10, 27, 20, 0), true},
+		{"before opening after the DST day", "08:00:00", "20:00:00",
+			time.Date(2019, 10, 27, 6, 30, 0, 0, time.UTC), time.Date(2019, 10, 27, 8, 0, 0, 0, time.UTC), false},
+		{"overnight across the DST change", "22:00:00", "06:00:00", local(3, 30, 22, 0), local(3, 31, 6, 0), true},
+		{"overnight started the day before", "22:00:00", "06:00:00", local(3, 31, 1, 0), local(3, 31, 5, 0), true},
+		{"overnight past closing", "22:00:00", "06:00:00", local(3, 30, 22, 0), local(3, 31, 6, 30), false},
+		// 02:30 doesn't exist on the DST day, the opening moves to 03:30
+		{"opening in the DST gap", "02:30:00", "10:00:00", local(3, 31, 3, 30), local(3, 31, 10, 0), true},
+		{"before the opening in the DST gap", "02:30:00", "10:00:00",
+			time.Date(2019, 3, 31, 1, 0, 0, 0, time.UTC), time.Date(2019, 3, 31, 2, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		open, err := isOpen(tt.from, tt.to, "Europe/Paris", tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if open != tt.open {
+			t.Errorf("%s: open = %v, want %v", tt.name, open, tt.open)
+		}
+	}
+	if _, err := isOpen("08:00:00", "20:00:00", "Nowhere/City", time.Now(), time.Now()); err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+}
+
+func TestAtClock(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	havana := mustLoadLocation(t, "America/Havana")
+	clock := func(s string) time.Time {
+		c, err := time.Parse(clockFormat, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2019, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		day   time.Time
+		clock string
+		loc   *time.Location
+		want  time.Time
+	}{
+		{"winter time", day(1, 10), "10:00:00", paris, time.Date(2019, 1, 10, 9, 0, 0, 0, time.UTC)},
+		{"after the clocks go forward", day(3, 31), "10:00:00", paris, time.Date(2019, 3, 31, 8, 0, 0, 0, time.UTC)},
+		// 02:30 is skipped, it is read with the winter offset as 03:30 summer time
+		{"in the gap", day(3, 31), "02:30:00", paris, time.Date(2019, 3, 31, 1, 30, 0, 0, time.UTC)},
+		// 02:30 happens twice, the first one is in summer time
+		{"repeated", day(10, 27), "02:30:00", paris, time.Date(2019, 10, 27, 0, 30, 0, 0, time.UTC)},
+		{"after the clocks go back", day(10, 27), "10:00:00", paris, time.Date(2019, 10, 27, 9, 0, 0, 0, time.UTC)},
+		// the clocks of Cuba go forward at midnight, 00:30 becomes 01:30
+		{"in a gap at midnight", day(3, 10), "00:30:00", havana, time.Date(2019, 3, 10, 5, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := atClock(tt.day, clock(tt.clock), tt.loc); !got.Equal(tt.want) {
+			t.Errorf("%s: atClock = %v, want %v", tt.name, got.UTC(), tt.want)
+		}
+	}
+}
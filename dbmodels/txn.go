@@ -0,0 +1,192 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// localPublish is set when the committed events are published in this instance directly,
+// it is cleared while a Listener fans out the events of all the instances
+var localPublish int32 = 1
+
+// txn is a transaction collecting the booking events, they are published once it is committed
+type txn struct {
+	*sqlx.Tx
+	events []events.BookingEvent
+}
+
+func begin() (*txn, error) {
+	tx, err := database.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx}, nil
+}
+
+// emit records a booking event in the events log with its webhook deliveries, it is published at commit
+func (t *txn) emit(eventType string, b *Booking, previousState string) error {
+	payload, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	ev := events.BookingEvent{
+		Type:          eventType,
+		BookingID:     b.ID,
+		CustomerID:    b.CustomerID,
+		RoomID:        b.RoomID,
+		State:         b.State,
+		PreviousState: previousState,
+		Time:          time.Now().UTC(),
+		Booking:       payload,
+	}
+	err = t.Get(&ev, `
+		INSERT INTO booking_events (type, booking_id, customer_id, room_id, data_center_id, state,
+			previous_state, created_at, booking)
+		VALUES ($1, $2, $3, $4,
+			(SELECT h.data_center_id FROM rooms r JOIN hotels h ON h.id = r.hotel_id WHERE r.id = $4),
+			$5, NULLIF($6, ''), $7, $8)
+		RETURNING id, tx_id, data_center_id`,
+		ev.Type, ev.BookingID, ev.CustomerID, ev.RoomID, ev.State, ev.PreviousState, ev.Time, string(payload))
+	if err != nil {
+		return err
+	}
+	if err = createWebhookDeliveries(t, &ev); err != nil {
+		return err
+	}
+	// the notification is delivered to the listeners when the transaction commits
+	if _, err = t.Exec(`SELECT pg_notify($1, $2)`, BookingEventsChannel, strconv.FormatInt(ev.ID, 10)); err != nil {
+		return err
+	}
+	t.events = append(t.events, ev)
+	return nil
+}
+
+func (t *txn) commit() error {
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	fanOut := atomic.LoadInt32(&localPublish) == 1
+	for _, ev := range t.events {
+		events.PublishLocal(ev)
+		if fanOut {
+			events.Publish(ev)
+		}
+	}
+	t.events = nil
+	return nil
+}
+
+// inTx runs fn in a transaction, the transaction is committed when fn succeeds
+func inTx(fn func(tx *txn) (int, error)) (int, error) {
+	tx, err := begin()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	state, err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return state, err
+	}
+	if err = tx.commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return state, nil
+}
+
+const bookingEventColumns = `id, tx_id, type, booking_id, customer_id, room_id, data_center_id, state,
+	COALESCE(previous_state, '') AS previous_state, created_at, booking`
+
+// EventCursor is a position in the booking events log. The events are read in the order of
+// their transaction IDs, the IDs of the events are only taken at insert time and a transaction
+// committing late would put its events behind a cursor already past them.
+type EventCursor struct {
+	TxID int64
+	ID   int64
+}
+
+// CursorOf returns the position of the event in the log
+func CursorOf(ev *events.BookingEvent) EventCursor {
+	return EventCursor{TxID: ev.TxID, ID: ev.ID}
+}
+
+// String formats the cursor as "<tx_id>-<id>", e.g. for the Server-Sent Events IDs
+func (cur EventCursor) String() string {
+	return fmt.Sprintf("%d-%d", cur.TxID, cur.ID)
+}
+
+// ParseEventCursor reads a cursor formatted by String. A plain event ID, from before the
+// events were ordered by transaction, is looked up in the log.
+func ParseEventCursor(str string) (EventCursor, error) {
+	var cur EventCursor
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		txID, err := strconv.ParseInt(str[:i], 10, 64)
+		if err != nil {
+			return cur, fmt.Errorf("invalid event cursor %q", str)
+		}
+		id, err := strconv.ParseInt(str[i+1:], 10, 64)
+		if err != nil {
+			return cur, fmt.Errorf("invalid event cursor %q", str)
+		}
+		return EventCursor{TxID: txID, ID: id}, nil
+	}
+	id, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return cur, fmt.Errorf("invalid event cursor %q", str)
+	}
+	err = database.Get(&cur, `SELECT tx_id AS txid, id FROM booking_events WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return cur, fmt.Errorf("unknown event %d", id)
+	}
+	return cur, err
+}
+
+// GetBookingEventsHead returns the cursor of the end of the log, the events committed from
+// then on follow it
+func GetBookingEventsHead() (EventCursor, error) {
+	var xmin int64
+	err := database.Get(&xmin, `SELECT txid_snapshot_xmin(txid_current_snapshot())`)
+	// the cursor precedes the events of all the transactions from xmin on, including
+	// the few of them already committed
+	return EventCursor{TxID: xmin - 1, ID: math.MaxInt64}, err
+}
+
+// GetBookingEventsAfter returns up to limit events of the log following the cursor. Only the
+// events of the transactions older than the oldest running one are returned, no event can be
+// committed before them anymore.
+func GetBookingEventsAfter(cur EventCursor, limit int) ([]events.BookingEvent, error) {
+	evs := []events.BookingEvent{}
+	err := database.Select(&evs, `SELECT `+bookingEventColumns+` FROM booking_events
+		WHERE (tx_id, id) > ($1, $2) AND tx_id < txid_snapshot_xmin(txid_current_snapshot())
+		ORDER BY tx_id, id LIMIT $3`, cur.TxID, cur.ID, limit)
+	return evs, err
+}
+
+// RoomBookingEvent is a booking event of the log with the provider of its room
+type RoomBookingEvent struct {
+	events.BookingEvent
+	Provider *uuid.UUID `db:"provider"`
+}
+
+// GetRoomBookingEventsAfter returns the events of GetBookingEventsAfter with the providers of
+// their rooms, the events of the other providers still move the cursor of the reader.
+func GetRoomBookingEventsAfter(cur EventCursor, limit int) ([]RoomBookingEvent, error) {
+	evs := []RoomBookingEvent{}
+	err := database.Select(&evs, `SELECT e.*, r.provider FROM (
+			SELECT `+bookingEventColumns+` FROM booking_events
+			WHERE (tx_id, id) > ($1, $2) AND tx_id < txid_snapshot_xmin(txid_current_snapshot())
+			ORDER BY tx_id, id LIMIT $3
+		) e LEFT JOIN rooms r ON r.id = e.room_id
+		ORDER BY e.tx_id, e.id`, cur.TxID, cur.ID, limit)
+	return evs, err
+}
@@ -1,9 +1,11 @@
 package dbmodels
 
 import (
+	"encoding/json"
 	"time"
 	"workflow"
 
+	"github.com/lib/pq"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -32,6 +34,44 @@ type Booking struct {
 	Transitions             *workflow.Transitions `db:"-" json:"transitions"`
 	BookingRequestEmail     *string               `db:"booking_request_email" json:"booking_request_email,omitempty"`
 	BookingRequestFromEmail *string               `db:"booking_request_from_email" json:"booking_request_from_email,omitempty"`
+	SeriesID                *uuid.UUID            `db:"series_id" json:"series_id"`
+	ExpiresAt               *time.Time            `db:"expires_at" json:"expires_at,omitempty"`
+	PartySize               int16                 `db:"party_size" json:"party_size"`
+	RatePlanID              *uuid.UUID            `db:"rate_plan_id" json:"rate_plan_id"`
+	TotalPrice              *int64                `db:"total_price" json:"total_price"`
+	Currency                *string               `db:"currency" json:"currency"`
+	CancellationPolicyID    *uuid.UUID            `db:"cancellation_policy_id" json:"cancellation_policy_id"`
+	CancellationFee         *int64                `db:"cancellation_fee" json:"cancellation_fee"`
+	CancelledAt             *time.Time            `db:"cancelled_at" json:"cancelled_at"`
+	AwaitingSince           *time.Time            `db:"awaiting_since" json:"awaiting_since,omitempty"`
+	EscalatedAt             *time.Time            `db:"escalated_at" json:"escalated_at,omitempty"`
+	Local                   *LocalTimes           `db:"-" json:"local,omitempty"`
+	MovedFrom               *uuid.UUID            `db:"-" json:"moved_from,omitempty"`
+	Message                 *BookingMessage       `db:"-" json:"message,omitempty"`
+	Occurrences             []Booking             `db:"-" json:"occurrences,omitempty"`
+	Room                    *Room                 `db:"-" json:"room,omitempty"`
+	Hotel                   *Hotel                `db:"-" json:"hotel,omitempty"`
+}
+
+// Hotel struct
+type Hotel struct {
+	ID           uuid.UUID `db:"id" json:"id"`
+	Name         string    `db:"name" json:"name"`
+	DataCenterID uuid.UUID `db:"data_center_id" json:"data_center_id"`
+	Description  *string   `db:"description" json:"description"`
+	TimeZone     string    `db:"time_zone" json:"time_zone"`
+}
+
+// HotelPatch ...
+type HotelPatch struct {
+	TimeZone *string `json:"time_zone"`
+}
+
+// LocalTimes are the times of a booking in the time zone of its hotel
+type LocalTimes struct {
+	TimeZone  string    `json:"time_zone"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
 }
 
 // Room struct
@@ -56,6 +96,17 @@ type RoomAvailability struct {
 	EndTime   *time.Time `db:"end_time" json:"end_time"`
 }
 
+// RoomAvailabilitySlot is a period during which the occupancy of the room doesn't change,
+// a room which isn't shared has a capacity of 1, Blocked is the kind of the block closing it
+type RoomAvailabilitySlot struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Capacity  int       `json:"capacity"`
+	Occupied  int       `json:"occupied"`
+	Remaining int       `json:"remaining"`
+	Blocked   string    `json:"blocked,omitempty"`
+}
+
 // BookingPost ...
 type BookingPost struct {
 	RoomID                  uuid.UUID             `db:"room_id" json:"room_id"`
@@ -72,6 +123,10 @@ type BookingPost struct {
 	Transitions             *workflow.Transitions `db:"-" json:"transitions"`
 	BookingRequestEmail     *string               `db:"booking_request_email" json:"booking_request_email,omitempty"`
 	BookingRequestFromEmail *string               `db:"booking_request_from_email" json:"booking_request_from_email,omitempty"`
+	RRule                   *string               `db:"-" json:"rrule,omitempty"`
+	ExDates                 []time.Time           `db:"-" json:"exdates,omitempty"`
+	ExpiresAt               *time.Time            `db:"expires_at" json:"-"`
+	PartySize               int16                 `db:"party_size" json:"party_size"`
 }
 
 // BookingPatch ...
@@ -89,4 +144,460 @@ type BookingPatch struct {
 	Transitions             *workflow.Transitions `db:"-" json:"transitions"`
 	BookingRequestEmail     *string               `db:"booking_request_email" json:"booking_request_email,omitempty"`
 	BookingRequestFromEmail *string               `db:"booking_request_from_email" json:"booking_request_from_email,omitempty"`
+	PartySize               *int16                `db:"party_size" json:"party_size"`
+	// WaiveCancellationFee is set when the provider cancels the booking
+	WaiveCancellationFee bool `db:"-" json:"-"`
+}
+
+// BulkOperation is a single create, patch or delete of a bulk request
+type BulkOperation struct {
+	Op     string        `json:"op"`
+	ID     *uuid.UUID    `json:"id"`
+	Create *BookingPost  `json:"create"`
+	Patch  *BookingPatch `json:"patch"`
+}
+
+// BulkRequest ...
+type BulkRequest struct {
+	Operations []BulkOperation `json:"operations"`
+}
+
+// BulkResult is the outcome of a single bulk operation
+type BulkResult struct {
+	Index   int        `json:"index"`
+	Op      string     `json:"op"`
+	ID      *uuid.UUID `json:"id"`
+	Status  int        `json:"status"`
+	Message *string    `json:"message,omitempty"`
+	Booking *Booking   `json:"booking,omitempty"`
+}
+
+// BulkResponse is the multi-status body of a bulk request
+type BulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// BookingHoldConfirm is the body of a hold confirmation
+type BookingHoldConfirm struct {
+	State string `json:"state"`
+}
+
+// WaitlistEntry is a customer waiting for a slot of a room, the hold offered when the slot
+// is freed is referenced by BookingID
+type WaitlistEntry struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	RoomID      uuid.UUID  `db:"room_id" json:"room_id"`
+	CustomerID  uuid.UUID  `db:"customer_id" json:"customer_id"`
+	RequestorID uuid.UUID  `db:"requestor_id" json:"requestor_id"`
+	StartTime   time.Time  `db:"start_time" json:"start_time"`
+	EndTime     time.Time  `db:"end_time" json:"end_time"`
+	Email       *string    `db:"email" json:"email"`
+	Description *string    `db:"description" json:"description"`
+	PartySize   int16      `db:"party_size" json:"party_size"`
+	Status      string     `db:"status" json:"status"`
+	BookingID   *uuid.UUID `db:"booking_id" json:"booking_id"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	OfferedAt   *time.Time `db:"offered_at" json:"offered_at"`
+}
+
+// WaitlistEntryPost is the body of a waitlist join
+type WaitlistEntryPost struct {
+	RoomID      uuid.UUID `json:"room_id"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	PartySize   int16     `json:"party_size"`
+	Email       *string   `json:"email"`
+	Description *string   `json:"description"`
+}
+
+// RatePlan prices the bookings of a room, or of the rooms of a type in a hotel, the rates are
+// amounts in the minor unit of the currency per night or per started hour
+type RatePlan struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	ProviderID  uuid.UUID  `db:"provider_id" json:"provider_id"`
+	HotelID     uuid.UUID  `db:"hotel_id" json:"hotel_id"`
+	RoomID      *uuid.UUID `db:"room_id" json:"room_id"`
+	RoomType    *string    `db:"room_type" json:"room_type"`
+	Name        string     `db:"name" json:"name"`
+	Currency    string     `db:"currency" json:"currency"`
+	Unit        string     `db:"unit" json:"unit"`
+	BaseRate    int64      `db:"base_rate" json:"base_rate"`
+	WeekendRate *int64     `db:"weekend_rate" json:"weekend_rate"`
+	Seasons     Seasons    `db:"seasons" json:"seasons"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// RatePlanPost is the body of a rate plan creation or replacement, it targets a room or a
+// room type of a hotel
+type RatePlanPost struct {
+	HotelID     *uuid.UUID `json:"hotel_id"`
+	RoomID      *uuid.UUID `json:"room_id"`
+	RoomType    *string    `json:"room_type"`
+	Name        string     `json:"name"`
+	Currency    string     `json:"currency"`
+	Unit        string     `json:"unit"`
+	BaseRate    int64      `json:"base_rate"`
+	WeekendRate *int64     `json:"weekend_rate"`
+	Seasons     Seasons    `json:"seasons"`
+}
+
+// Season overrides the rates of a rate plan from a date to another one, both included
+type Season struct {
+	Name        string `json:"name"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Rate        int64  `json:"rate"`
+	WeekendRate *int64 `json:"weekend_rate,omitempty"`
+}
+
+// Seasons are stored as a JSON array
+type Seasons []Season
+
+// Quote is the price of a stay in a room
+type Quote struct {
+	RoomID     uuid.UUID   `json:"room_id"`
+	RatePlanID uuid.UUID   `json:"rate_plan_id"`
+	StartTime  time.Time   `json:"start_time"`
+	EndTime    time.Time   `json:"end_time"`
+	Currency   string      `json:"currency"`
+	Unit       string      `json:"unit"`
+	Total      int64       `json:"total"`
+	Lines      []QuoteLine `json:"lines"`
+}
+
+// QuoteLine is the price of the nights or hours of a day
+type QuoteLine struct {
+	Date     string `json:"date"`
+	Quantity int    `json:"quantity"`
+	Rate     int64  `json:"rate"`
+	Amount   int64  `json:"amount"`
+	Season   string `json:"season,omitempty"`
+}
+
+// CancellationPolicy charges a percentage of the price of the bookings of a room, or of the
+// rooms of a hotel, cancelled less than FreeUntilHours hours before their start
+type CancellationPolicy struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	ProviderID     uuid.UUID  `db:"provider_id" json:"provider_id"`
+	HotelID        uuid.UUID  `db:"hotel_id" json:"hotel_id"`
+	RoomID         *uuid.UUID `db:"room_id" json:"room_id"`
+	Name           string     `db:"name" json:"name"`
+	FreeUntilHours int        `db:"free_until_hours" json:"free_until_hours"`
+	FeePercent     int        `db:"fee_percent" json:"fee_percent"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// CancellationPolicyPost is the body of a cancellation policy creation or replacement, it
+// targets a room or a whole hotel
+type CancellationPolicyPost struct {
+	HotelID        *uuid.UUID `json:"hotel_id"`
+	RoomID         *uuid.UUID `json:"room_id"`
+	Name           string     `json:"name"`
+	FreeUntilHours int        `json:"free_until_hours"`
+	FeePercent     int        `json:"fee_percent"`
+}
+
+// CancellationPreview tells what cancelling a booking costs, the fee is unknown for the
+// bookings without price
+type CancellationPreview struct {
+	BookingID  uuid.UUID  `json:"booking_id"`
+	PolicyID   *uuid.UUID `json:"policy_id"`
+	PolicyName string     `json:"policy_name,omitempty"`
+	FreeUntil  *time.Time `json:"free_until"`
+	FeePercent int        `json:"fee_percent"`
+	Fee        *int64     `json:"fee"`
+	Currency   *string    `json:"currency"`
+}
+
+// Payment is the payment of a booking, the amounts are in the minor unit of the currency
+type Payment struct {
+	ID           uuid.UUID            `db:"id" json:"id"`
+	BookingID    uuid.UUID            `db:"booking_id" json:"booking_id"`
+	Provider     string               `db:"provider" json:"provider"`
+	Reference    *string              `db:"reference" json:"reference"`
+	Currency     string               `db:"currency" json:"currency"`
+	Amount       int64                `db:"amount" json:"amount"`
+	Captured     int64                `db:"captured" json:"captured"`
+	Refunded     int64                `db:"refunded" json:"refunded"`
+	Status       string               `db:"status" json:"status"`
+	CreatedAt    time.Time            `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time            `db:"updated_at" json:"updated_at"`
+	Transactions []PaymentTransaction `db:"-" json:"transactions,omitempty"`
+}
+
+// PaymentTransaction is an operation of the payment provider on a payment
+type PaymentTransaction struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	PaymentID uuid.UUID `db:"payment_id" json:"payment_id"`
+	Operation string    `db:"operation" json:"operation"`
+	Amount    int64     `db:"amount" json:"amount"`
+	Status    string    `db:"status" json:"status"`
+	Reference *string   `db:"reference" json:"reference"`
+	Message   *string   `db:"message" json:"message"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// CalendarToken grants access to the calendar feed of a customer
+type CalendarToken struct {
+	Token      string     `db:"token" json:"token"`
+	CustomerID uuid.UUID  `db:"customer_id" json:"customer_id"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at"`
+}
+
+// BookingFilter holds the filters of the booking listings
+type BookingFilter struct {
+	DataCenters []uuid.UUID
+	Customers   []uuid.UUID
+	Requestors  []uuid.UUID
+	Bookings    []uuid.UUID
+	States      []string
+	FromDate    *time.Time
+	ToDate      *time.Time
+	// Role and OwnerID scope the bookings to the ones of the customer or of the rooms of the provider
+	Role    string
+	OwnerID uuid.UUID
+}
+
+// BookingDocument describes the document uploaded to a booking
+type BookingDocument struct {
+	BookingID   uuid.UUID `db:"booking_id" json:"booking_id"`
+	Key         string    `db:"storage_key" json:"-"`
+	FileName    string    `db:"file_name" json:"file_name"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Size        int64     `db:"size" json:"size"`
+	SHA256      string    `db:"sha256" json:"sha256"`
+	UploadedAt  time.Time `db:"uploaded_at" json:"uploaded_at"`
+}
+
+// WebhookEndpoint is a provider URL receiving the booking events
+type WebhookEndpoint struct {
+	ID         uuid.UUID      `db:"id" json:"id"`
+	ProviderID uuid.UUID      `db:"provider_id" json:"provider_id"`
+	URL        string         `db:"url" json:"url"`
+	Secret     string         `db:"secret" json:"secret,omitempty"`
+	EventTypes pq.StringArray `db:"event_types" json:"event_types"`
+	Active     bool           `db:"active" json:"active"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+}
+
+// WebhookEndpointPost ...
+type WebhookEndpointPost struct {
+	URL        string   `json:"url"`
+	Secret     *string  `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+// WebhookDelivery is the delivery of an event to a webhook endpoint
+type WebhookDelivery struct {
+	ID             uuid.UUID       `db:"id" json:"id"`
+	EndpointID     uuid.UUID       `db:"endpoint_id" json:"endpoint_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at"`
+}
+
+// RoomBlock closes a room, or the rooms of the provider in a hotel, for a blackout or a
+// maintenance, a block with an rrule recurs with the duration of its first occurrence
+type RoomBlock struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	ProviderID uuid.UUID  `db:"provider_id" json:"provider_id"`
+	HotelID    uuid.UUID  `db:"hotel_id" json:"hotel_id"`
+	RoomID     *uuid.UUID `db:"room_id" json:"room_id"`
+	Kind       string     `db:"kind" json:"kind"`
+	Reason     *string    `db:"reason" json:"reason"`
+	StartTime  time.Time  `db:"start_time" json:"start_time"`
+	EndTime    time.Time  `db:"end_time" json:"end_time"`
+	RRule      *string    `db:"rrule" json:"rrule"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// RoomBlockPost is the body of a room block creation or replacement, it targets a room or
+// the rooms of the provider in a hotel
+type RoomBlockPost struct {
+	HotelID   *uuid.UUID `json:"hotel_id"`
+	RoomID    *uuid.UUID `json:"room_id"`
+	Kind      string     `json:"kind"`
+	Reason    *string    `json:"reason"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   time.Time  `json:"end_time"`
+	RRule     *string    `json:"rrule"`
+}
+
+// RoomSearch are the criteria of a search of the rooms available for a party in the
+// [StartTime, EndTime) period
+type RoomSearch struct {
+	HotelID     *uuid.UUID
+	DataCenters []uuid.UUID
+	Type        string
+	PartySize   int
+	StartTime   time.Time
+	EndTime     time.Time
+	Limit       int
+}
+
+// RoomSearchResult is a room available for the searched period, Remaining is the number of
+// persons it can still take then
+type RoomSearchResult struct {
+	Room
+	HotelName    string    `db:"hotel_name" json:"hotel_name"`
+	DataCenterID uuid.UUID `db:"data_center_id" json:"data_center_id"`
+	TimeZone     string    `db:"time_zone" json:"time_zone"`
+	Capacity     int       `db:"capacity" json:"capacity"`
+	Remaining    int       `db:"remaining" json:"remaining"`
+}
+
+// BookingMove is the body of a booking move to another room, the booking exchanges its room
+// with the booking SwapWith when it is given
+type BookingMove struct {
+	RoomID   *uuid.UUID `json:"room_id"`
+	SwapWith *uuid.UUID `json:"swap_with"`
+	AnyType  bool       `json:"any_type"`
+	Reason   *string    `json:"reason"`
+}
+
+// BookingRoomMove records a move of a booking to another room
+type BookingRoomMove struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	BookingID   uuid.UUID  `db:"booking_id" json:"booking_id"`
+	FromRoomID  uuid.UUID  `db:"from_room_id" json:"from_room_id"`
+	ToRoomID    uuid.UUID  `db:"to_room_id" json:"to_room_id"`
+	SwappedWith *uuid.UUID `db:"swapped_with" json:"swapped_with"`
+	Reason      *string    `db:"reason" json:"reason"`
+	MovedBy     uuid.UUID  `db:"moved_by" json:"moved_by"`
+	MovedAt     time.Time  `db:"moved_at" json:"moved_at"`
+}
+
+// ApprovalSLA is the time the provider has to answer the booking requests of the rooms of a
+// hotel, the requests still awaiting an answer after it are escalated or rejected
+type ApprovalSLA struct {
+	ID              uuid.UUID `db:"id" json:"id"`
+	ProviderID      uuid.UUID `db:"provider_id" json:"provider_id"`
+	HotelID         uuid.UUID `db:"hotel_id" json:"hotel_id"`
+	ResponseMinutes int       `db:"response_minutes" json:"response_minutes"`
+	BreachAction    string    `db:"breach_action" json:"breach_action"`
+	EscalationEmail *string   `db:"escalation_email" json:"escalation_email"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// ApprovalSLAPost is the body of an approval SLA creation or replacement
+type ApprovalSLAPost struct {
+	HotelID         uuid.UUID `json:"hotel_id"`
+	ResponseMinutes int       `json:"response_minutes"`
+	BreachAction    string    `json:"breach_action"`
+	EscalationEmail *string   `json:"escalation_email"`
+}
+
+// ApprovalQueueItem is a booking request awaiting the answer of the provider, the deadline is
+// set by the approval SLA of its hotel
+type ApprovalQueueItem struct {
+	Booking
+	Deadline *time.Time `db:"deadline" json:"deadline"`
+	Overdue  bool       `db:"-" json:"overdue"`
+}
+
+// ApprovalMetrics are the approval latencies of the booking requests of a hotel answered in
+// a period, in seconds from the time they started awaiting the provider
+type ApprovalMetrics struct {
+	HotelID        uuid.UUID `db:"hotel_id" json:"hotel_id"`
+	Answered       int       `db:"answered" json:"answered"`
+	Approved       int       `db:"approved" json:"approved"`
+	Rejected       int       `db:"rejected" json:"rejected"`
+	AutoRejected   int       `db:"auto_rejected" json:"auto_rejected"`
+	Breached       int       `db:"breached" json:"breached"`
+	AverageSeconds float64   `db:"average_seconds" json:"average_seconds"`
+	MedianSeconds  float64   `db:"median_seconds" json:"median_seconds"`
+	P90Seconds     float64   `db:"p90_seconds" json:"p90_seconds"`
+}
+
+// BookingMessage is a message of the thread of a booking between the customer and the provider,
+// ReadAt is set once the other side read it
+type BookingMessage struct {
+	ID               uuid.UUID  `db:"id" json:"id"`
+	BookingID        uuid.UUID  `db:"booking_id" json:"booking_id"`
+	AuthorRole       string     `db:"author_role" json:"author_role"`
+	AuthorID         uuid.UUID  `db:"author_id" json:"author_id"`
+	Body             string     `db:"body" json:"body"`
+	AttachmentKey    *string    `db:"attachment_key" json:"-"`
+	AttachmentName   *string    `db:"attachment_name" json:"attachment_name,omitempty"`
+	AttachmentType   *string    `db:"attachment_type" json:"attachment_type,omitempty"`
+	AttachmentSize   *int64     `db:"attachment_size" json:"attachment_size,omitempty"`
+	AttachmentSHA256 *string    `db:"attachment_sha256" json:"attachment_sha256,omitempty"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	ReadAt           *time.Time `db:"read_at" json:"read_at"`
+}
+
+// BookingMessagePost is the body of a message without attachment
+type BookingMessagePost struct {
+	Body string `json:"body"`
+}
+
+// ReportQuery selects the local dates [From, To) of the hotels of a report, its grouping per
+// room or per hotel and the length of its periods
+type ReportQuery struct {
+	From     time.Time
+	To       time.Time
+	HotelID  *uuid.UUID
+	GroupBy  string
+	Interval string
+}
+
+// OccupancyReportRow is the occupancy of a room, or of the rooms of a hotel, during the period
+// starting at the local date Period. The blocked time of the opening hours isn't bookable, the
+// occupied time is weighted by the persons of the shared rooms.
+type OccupancyReportRow struct {
+	Period          string     `json:"period"`
+	HotelID         uuid.UUID  `json:"hotel_id"`
+	RoomID          *uuid.UUID `json:"room_id,omitempty"`
+	Rooms           int        `json:"rooms"`
+	Bookings        int        `json:"bookings"`
+	OpenSeconds     int64      `json:"open_seconds"`
+	BlockedSeconds  int64      `json:"blocked_seconds"`
+	BookableSeconds int64      `json:"bookable_seconds"`
+	OccupiedSeconds int64      `json:"occupied_seconds"`
+	Occupancy       float64    `json:"occupancy"`
+}
+
+// BookingStatsReportRow counts by state the booking requests of a room, or of a hotel, requested
+// in the period, the lead times are the ones of the requests which aren't drafts
+type BookingStatsReportRow struct {
+	HotelID            uuid.UUID  `db:"hotel_id" json:"hotel_id"`
+	RoomID             *uuid.UUID `db:"room_id" json:"room_id,omitempty"`
+	Total              int        `db:"total" json:"total"`
+	Draft              int        `db:"draft" json:"draft"`
+	Pending            int        `db:"pending" json:"pending"`
+	PendingResp        int        `db:"pending_resp" json:"pending_resp"`
+	Booked             int        `db:"booked" json:"booked"`
+	Completed          int        `db:"completed" json:"completed"`
+	Rejected           int        `db:"rejected" json:"rejected"`
+	Cancelled          int        `db:"cancelled" json:"cancelled"`
+	CancellationRate   float64    `db:"-" json:"cancellation_rate"`
+	AverageLeadSeconds float64    `db:"average_lead_seconds" json:"average_lead_seconds"`
+	MedianLeadSeconds  float64    `db:"median_lead_seconds" json:"median_lead_seconds"`
+	P90LeadSeconds     float64    `db:"p90_lead_seconds" json:"p90_lead_seconds"`
+}
+
+// LeadTimeReportRow is the number of booking requests of a room, or of a hotel, requested in the
+// period between MinDays and MaxDays before their start, Share is their part of the requests
+type LeadTimeReportRow struct {
+	HotelID  uuid.UUID  `db:"hotel_id" json:"hotel_id"`
+	RoomID   *uuid.UUID `db:"room_id" json:"room_id,omitempty"`
+	MinDays  int        `db:"-" json:"min_days"`
+	MaxDays  *int       `db:"-" json:"max_days"`
+	Bookings int        `db:"bookings" json:"bookings"`
+	Share    float64    `db:"-" json:"share"`
 }
@@ -0,0 +1,138 @@
+package dbmodels
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Waitlist entry states
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistOffered = "offered"
+)
+
+const waitlistColumns = `id, room_id, customer_id, requestor_id, start_time, end_time, party_size, email,
+	description, status, booking_id, created_at, offered_at`
+
+// WaitlistOffer is the hold created for a waitlisted customer
+type WaitlistOffer struct {
+	Entry WaitlistEntry
+	Hold  Booking
+}
+
+// JoinWaitlist puts the customer on the waitlist of the room for the given period
+func JoinWaitlist(customerID, requestorID uuid.UUID, body *WaitlistEntryPost) (*WaitlistEntry, int, error) {
+	if body.RoomID == uuid.Nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("room_id is mandatory")
+	}
+	if body.StartTime.IsZero() || body.EndTime.IsZero() {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time and end_time are mandatory")
+	}
+	if !body.StartTime.Before(body.EndTime) {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time must be before end_time")
+	}
+	if !body.StartTime.After(time.Now()) {
+		return nil, http.StatusBadRequest, fmt.Errorf("start_time must be in the future")
+	}
+	if body.PartySize < 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("party_size must be positive")
+	}
+	if body.PartySize == 0 {
+		body.PartySize = 1
+	}
+	if state, err := checkOpeningHours(database, body.RoomID, body.StartTime, body.EndTime); err != nil {
+		return nil, state, err
+	}
+	var e WaitlistEntry
+	err := database.Get(&e, `
+		INSERT INTO waitlist_entries (id, room_id, customer_id, requestor_id, start_time, end_time, party_size,
+			email, description, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+waitlistColumns,
+		uuid.NewV4(), body.RoomID, customerID, requestorID, body.StartTime, body.EndTime, body.PartySize,
+		body.Email, body.Description, WaitlistWaiting, time.Now().UTC())
+	if err != nil && strings.Contains(err.Error(), "foreign key") {
+		return nil, http.StatusNotFound, fmt.Errorf("room %s not found", body.RoomID)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &e, http.StatusOK, nil
+}
+
+// GetWaitlistEntries returns the waitlist entries of the customer
+func GetWaitlistEntries(customerID uuid.UUID) ([]WaitlistEntry, error) {
+	entries := []WaitlistEntry{}
+	err := database.Select(&entries, `SELECT `+waitlistColumns+` FROM waitlist_entries
+		WHERE customer_id = $1 ORDER BY created_at`, customerID)
+	return entries, err
+}
+
+// LeaveWaitlist removes the waitlist entry of the customer, an offered hold is kept
+func LeaveWaitlist(customerID, id uuid.UUID) (int, error) {
+	res, err := database.Exec(`DELETE FROM waitlist_entries WHERE id = $1 AND customer_id = $2`, id, customerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("waitlist entry %s not found", id)
+	}
+	return http.StatusNoContent, nil
+}
+
+// OfferWaitlistedSlots creates a hold lasting holdDuration for the waiting entries of the room
+// overlapping the [start, end) period, in the order they joined, as long as their slot is free
+func OfferWaitlistedSlots(roomID uuid.UUID, start, end time.Time, holdDuration time.Duration) ([]WaitlistOffer, error) {
+	var offers []WaitlistOffer
+	_, err := inTx(func(tx *txn) (int, error) {
+		if state, err := lockRoom(tx, roomID); err != nil {
+			return state, err
+		}
+		now := time.Now().UTC()
+		var entries []WaitlistEntry
+		err := tx.Select(&entries, `SELECT `+waitlistColumns+` FROM waitlist_entries
+			WHERE room_id = $1 AND status = $2 AND start_time < $4 AND end_time > $3 AND start_time > $5
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED`, roomID, WaitlistWaiting, start, end, now)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		for _, e := range entries {
+			expiresAt := now.Add(holdDuration)
+			hold, state, err := insertBooking(tx, &BookingPost{
+				RoomID:              roomID,
+				CustomerID:          e.CustomerID,
+				RequestorID:         e.RequestorID,
+				StartTime:           e.StartTime,
+				EndTime:             e.EndTime,
+				PartySize:           e.PartySize,
+				State:               "draft",
+				Description:         e.Description,
+				BookingRequestEmail: e.Email,
+				ExpiresAt:           &expiresAt,
+			}, nil)
+			if state == http.StatusConflict || state == http.StatusBadRequest {
+				// the slot of the entry is still taken or out of the opening hours
+				continue
+			}
+			if err != nil {
+				return state, err
+			}
+			err = tx.Get(&e, `UPDATE waitlist_entries SET status = $2, booking_id = $3, offered_at = $4
+				WHERE id = $1 RETURNING `+waitlistColumns, e.ID, WaitlistOffered, hold.ID, now)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			offers = append(offers, WaitlistOffer{Entry: e, Hold: *hold})
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
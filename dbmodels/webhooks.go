@@ -0,0 +1,270 @@
+package dbmodels
+
+import (
+	"bookings/events"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+var webhookEventTypes = map[string]bool{
+	events.BookingCreated:   true,
+	events.BookingUpdated:   true,
+	events.BookingDeleted:   true,
+	events.BookingExpired:   true,
+	events.BookingMoved:     true,
+	events.BookingEscalated: true,
+	events.BookingMessage:   true,
+}
+
+// nonPublicNetworks are the ranges reserved for shared, benchmarking and protocol use which
+// the net.IP predicates leave out
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// IsPublicIP tells whether the webhooks may be sent to the address, the loopback, link-local,
+// private and other reserved addresses of the internal network are refused
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range nonPublicNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookHost refuses the hosts resolving to an address which is not public
+func checkWebhookHost(host string) error {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host %q", host)
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("webhook host %q resolves to the non public address %s", host, ip)
+		}
+	}
+	return nil
+}
+
+const webhookEndpointColumns = `id, provider_id, url, secret, event_types, active, created_at`
+
+const webhookDeliveryColumns = `id, endpoint_id, event_type, payload, status, attempts, last_status_code,
+	last_error, next_attempt_at, created_at, delivered_at`
+
+// CreateWebhookEndpoint registers a webhook endpoint of the provider, a secret is generated when none is given
+func CreateWebhookEndpoint(providerID uuid.UUID, body *WebhookEndpointPost) (*WebhookEndpoint, int, error) {
+	u, err := url.Parse(body.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid webhook url %q", body.URL)
+	}
+	// the dispatcher checks the addresses again when it connects, the host may be rebound
+	if err = checkWebhookHost(u.Hostname()); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	for _, t := range body.EventTypes {
+		if !webhookEventTypes[t] {
+			return nil, http.StatusBadRequest, fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	w := WebhookEndpoint{
+		ID:         uuid.NewV4(),
+		ProviderID: providerID,
+		URL:        body.URL,
+		EventTypes: pq.StringArray(body.EventTypes),
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if w.EventTypes == nil {
+		w.EventTypes = pq.StringArray{}
+	}
+	if body.Secret != nil && *body.Secret != "" {
+		w.Secret = *body.Secret
+	} else {
+		buf := make([]byte, 32)
+		if _, err = rand.Read(buf); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		w.Secret = hex.EncodeToString(buf)
+	}
+	_, err = database.NamedExec(`
+		INSERT INTO webhook_endpoints (`+webhookEndpointColumns+`)
+		VALUES (:id, :provider_id, :url, :secret, :event_types, :active, :created_at)`, &w)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &w, http.StatusOK, nil
+}
+
+// GetWebhookEndpoints lists the webhook endpoints of the provider, the secrets are left out
+func GetWebhookEndpoints(providerID uuid.UUID) ([]WebhookEndpoint, error) {
+	endpoints := []WebhookEndpoint{}
+	err := database.Select(&endpoints, `SELECT `+webhookEndpointColumns+` FROM webhook_endpoints
+		WHERE provider_id = $1 ORDER BY created_at`, providerID)
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, err
+}
+
+// DeleteWebhookEndpoint removes a webhook endpoint of the provider with its delivery log
+func DeleteWebhookEndpoint(providerID, id uuid.UUID) (int, error) {
+	res, err := database.Exec(`DELETE FROM webhook_endpoints WHERE id = $1 AND provider_id = $2`, id, providerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusNotFound, fmt.Errorf("webhook endpoint %s not found", id)
+	}
+	return http.StatusNoContent, nil
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook endpoint of the provider, newest first
+func GetWebhookDeliveries(providerID, endpointID uuid.UUID, status string, pageNumber, perPage int) ([]WebhookDelivery, int, error) {
+	var total int
+	err := database.Get(&total, `
+		SELECT count(*) FROM webhook_deliveries d JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.endpoint_id = $1 AND e.provider_id = $2 AND ($3 = '' OR d.status::text = $3)`,
+		endpointID, providerID, status)
+	if err != nil {
+		return nil, 0, err
+	}
+	deliveries := []WebhookDelivery{}
+	err = database.Select(&deliveries, `
+		SELECT `+prefixColumns("d", webhookDeliveryColumns)+`
+		FROM webhook_deliveries d JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.endpoint_id = $1 AND e.provider_id = $2 AND ($3 = '' OR d.status::text = $3)
+		ORDER BY d.created_at DESC LIMIT $4 OFFSET $5`,
+		endpointID, providerID, status, perPage, (pageNumber-1)*perPage)
+	return deliveries, total, err
+}
+
+// ReplayWebhookDelivery schedules a delivery of the provider to be sent again right away
+func ReplayWebhookDelivery(providerID, id uuid.UUID) (*WebhookDelivery, int, error) {
+	var d WebhookDelivery
+	err := database.Get(&d, `
+		UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, next_attempt_at = now()
+		FROM webhook_endpoints e
+		WHERE d.id = $1 AND e.id = d.endpoint_id AND e.provider_id = $2
+		RETURNING `+prefixColumns("d", webhookDeliveryColumns), id, providerID)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("webhook delivery %s not found", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &d, http.StatusOK, nil
+}
+
+// createWebhookDeliveries queues the event for the active endpoints subscribed to it, the
+// endpoints of the provider of the booked room are selected. The deliveries are inserted in
+// the transaction of the event, they are committed, or lost, with it.
+func createWebhookDeliveries(tx *txn, ev *events.BookingEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var endpointIDs []uuid.UUID
+	err = tx.Select(&endpointIDs, `
+		SELECT e.id FROM webhook_endpoints e JOIN rooms r ON r.provider = e.provider_id
+		WHERE r.id = $2 AND e.active AND (cardinality(e.event_types) = 0 OR $1 = ANY(e.event_types))`,
+		ev.Type, ev.RoomID)
+	if err != nil {
+		return err
+	}
+	for _, endpointID := range endpointIDs {
+		_, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (id, endpoint_id, event_type, payload)
+			VALUES ($1, $2, $3, $4)`, uuid.NewV4(), endpointID, ev.Type, string(payload))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DueWebhookDelivery is a delivery to send with its endpoint
+type DueWebhookDelivery struct {
+	WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// ClaimWebhookDeliveries returns up to limit deliveries due to be sent and postpones them by
+// lease, so that other instances don't send them concurrently
+func ClaimWebhookDeliveries(limit int, lease time.Duration) ([]DueWebhookDelivery, error) {
+	due := []DueWebhookDelivery{}
+	err := database.Select(&due, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = now() + $2::interval
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= now()
+				ORDER BY next_attempt_at LIMIT $1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING `+webhookDeliveryColumns+`)
+		SELECT `+prefixColumns("c", webhookDeliveryColumns)+`, e.url, e.secret
+		FROM claimed c JOIN webhook_endpoints e ON e.id = c.endpoint_id`,
+		limit, fmt.Sprintf("%d seconds", int(lease.Seconds())))
+	return due, err
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt, a failed delivery is
+// retried at retryAt or marked as failed when retryAt is nil
+func RecordWebhookAttempt(id uuid.UUID, statusCode int, attemptErr error, retryAt *time.Time) error {
+	var code *int
+	if statusCode > 0 {
+		code = &statusCode
+	}
+	if attemptErr == nil {
+		_, err := database.Exec(`
+			UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1,
+				last_status_code = $2, last_error = NULL, delivered_at = now()
+			WHERE id = $1`, id, code)
+		return err
+	}
+	status := DeliveryFailed
+	next := time.Now().UTC()
+	if retryAt != nil {
+		status = DeliveryPending
+		next = *retryAt
+	}
+	_, err := database.Exec(`
+		UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1,
+			last_status_code = $3, last_error = $4, next_attempt_at = $5
+		WHERE id = $1`, id, status, code, attemptErr.Error(), next)
+	return err
+}
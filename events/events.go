@@ -0,0 +1,143 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Booking event types
+const (
+	BookingCreated   = "booking.created"
+	BookingUpdated   = "booking.updated"
+	BookingDeleted   = "booking.deleted"
+	BookingExpired   = "booking.expired"
+	BookingMoved     = "booking.moved"
+	BookingEscalated = "booking.escalated"
+	BookingMessage   = "booking.message"
+)
+
+// BookingEvent describes a change of a booking
+type BookingEvent struct {
+	ID            int64           `db:"id" json:"id"`
+	TxID          int64           `db:"tx_id" json:"-"`
+	Type          string          `db:"type" json:"type"`
+	BookingID     uuid.UUID       `db:"booking_id" json:"booking_id"`
+	CustomerID    uuid.UUID       `db:"customer_id" json:"customer_id"`
+	RoomID        uuid.UUID       `db:"room_id" json:"room_id"`
+	DataCenterID  *uuid.UUID      `db:"data_center_id" json:"data_center_id,omitempty"`
+	State         string          `db:"state" json:"state"`
+	PreviousState string          `db:"previous_state" json:"previous_state,omitempty"`
+	Time          time.Time       `db:"created_at" json:"time"`
+	Booking       json.RawMessage `db:"booking" json:"booking"`
+}
+
+// Handler receives the published events, it must not block
+type Handler func(ev BookingEvent)
+
+var (
+	mu       sync.RWMutex
+	handlers = map[int]Handler{}
+	local    = map[int]Handler{}
+	nextID   int
+)
+
+// Subscribe registers a handler for the booking events of all the instances sharing the
+// database, e.g. to update the state held by this instance
+func Subscribe(h Handler) {
+	subscribe(handlers, h)
+}
+
+// SubscribeLocal registers a handler for the booking events committed by this instance.
+// Every event reaches the local handlers of a single instance, they carry out the side
+// effects which must happen once, like sending notifications.
+func SubscribeLocal(h Handler) {
+	subscribe(local, h)
+}
+
+func subscribe(registry map[int]Handler, h Handler) int {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	registry[nextID] = h
+	return nextID
+}
+
+func unsubscribe(id int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(handlers, id)
+}
+
+// Publish delivers the event to the subscribers
+func Publish(ev BookingEvent) {
+	publish(handlers, ev)
+}
+
+// PublishLocal delivers an event committed by this instance to the local subscribers
+func PublishLocal(ev BookingEvent) {
+	publish(local, ev)
+}
+
+func publish(registry map[int]Handler, ev BookingEvent) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, h := range registry {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("Booking event handler failed on %s: %v", ev.Type, r)
+				}
+			}()
+			h(ev)
+		}()
+	}
+}
+
+// Subscription receives the booking events on a channel. The channel is closed when the
+// subscriber falls behind by more than the buffer, it has to resume from the events log then.
+type Subscription struct {
+	C <-chan BookingEvent
+
+	c      chan BookingEvent
+	id     int
+	lock   sync.Mutex
+	closed bool
+}
+
+// Listen creates a channel subscription with the given buffer size
+func Listen(buffer int) *Subscription {
+	s := &Subscription{c: make(chan BookingEvent, buffer)}
+	s.C = s.c
+	s.id = subscribe(handlers, s.deliver)
+	return s
+}
+
+func (s *Subscription) deliver(ev BookingEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.c <- ev:
+	default:
+		log.Warnf("Booking event subscriber is too slow, closing its subscription")
+		s.closed = true
+		close(s.c)
+	}
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	unsubscribe(s.id)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.c)
+	}
+}
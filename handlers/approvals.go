@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+	"time"
+
+	middlewares "git.ntteo.net/go-libs.git/gin-middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultApprovalMetricsPeriod is the reported period when no start is given
+const defaultApprovalMetricsPeriod = 30 * 24 * time.Hour
+
+// PostApprovalSLAPAPI creates an approval SLA of the provider
+func PostApprovalSLAPAPI(c *gin.Context) {
+	var body dbmodels.ApprovalSLAPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.CreateApprovalSLA(providerID, &body)
+	if err != nil {
+		log.Errorf("Error creating approval SLA %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PutApprovalSLAPAPI replaces an approval SLA of the provider
+func PutApprovalSLAPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad approval SLA ID"})
+		return
+	}
+	var body dbmodels.ApprovalSLAPost
+	err = c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.UpdateApprovalSLA(providerID, id, &body)
+	if err != nil {
+		log.Errorf("Error updating approval SLA %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetApprovalSLAsPAPI lists the approval SLAs of the provider
+func GetApprovalSLAsPAPI(c *gin.Context) {
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, err := dbmodels.GetApprovalSLAs(providerID)
+	if err != nil {
+		log.Errorf("Error listing approval SLAs %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteApprovalSLAPAPI removes an approval SLA of the provider
+func DeleteApprovalSLAPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad approval SLA ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	state, err := dbmodels.DeleteApprovalSLA(providerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetApprovalQueuePAPI lists the booking requests awaiting the answer of the provider, the oldest first
+func GetApprovalQueuePAPI(c *gin.Context) {
+	var perPage, pageNumber int
+	if pp, exists := c.Get("per_page"); exists {
+		perPage = pp.(int)
+	}
+	if p, exists := c.Get("page_number"); exists {
+		pageNumber = p.(int)
+	}
+	var hotelID *uuid.UUID
+	if str := c.Query("hotel_id"); str != "" {
+		id, err := uuid.FromString(str)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad hotel ID"})
+			return
+		}
+		hotelID = &id
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, total, err := dbmodels.GetApprovalQueue(providerID, hotelID, pageNumber, perPage)
+	if err != nil {
+		log.Errorf("Error listing approval queue %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	middlewares.WritePaginationHeaders(c, total)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetApprovalMetricsPAPI reports the approval latencies of the provider per hotel
+func GetApprovalMetricsPAPI(c *gin.Context) {
+	to := time.Now().UTC()
+	var err error
+	if str := c.Query("to"); str != "" {
+		if to, err = time.Parse(time.RFC3339, str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid to, RFC 3339 time expected"})
+			return
+		}
+	}
+	from := to.Add(-defaultApprovalMetricsPeriod)
+	if str := c.Query("from"); str != "" {
+		if from, err = time.Parse(time.RFC3339, str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid from, RFC 3339 time expected"})
+			return
+		}
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetApprovalMetrics(providerID, from, to)
+	if err != nil {
+		log.Errorf("Error getting approval metrics %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultAvailabilityPeriod is the reported period when no end is given
+const defaultAvailabilityPeriod = 7 * 24 * time.Hour
+
+// GetRoomAvailability reports the remaining capacity of a room slot by slot
+func GetRoomAvailability(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad room ID"})
+		return
+	}
+	from := time.Now().UTC()
+	if str := c.Query("from"); str != "" {
+		if from, err = time.Parse(time.RFC3339, str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid from, RFC 3339 time expected"})
+			return
+		}
+	}
+	to := from.Add(defaultAvailabilityPeriod)
+	if str := c.Query("to"); str != "" {
+		if to, err = time.Parse(time.RFC3339, str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid to, RFC 3339 time expected"})
+			return
+		}
+	}
+
+	response, state, err := dbmodels.GetRoomAvailability(id, from, to)
+	if err != nil {
+		log.Errorf("Error getting room availability %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SearchRooms lists the rooms available for a party in a period
+func SearchRooms(c *gin.Context) {
+	var search dbmodels.RoomSearch
+	var err error
+	if search.StartTime, err = time.Parse(time.RFC3339, c.Query("start_time")); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid start_time, RFC 3339 time expected"})
+		return
+	}
+	if search.EndTime, err = time.Parse(time.RFC3339, c.Query("end_time")); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid end_time, RFC 3339 time expected"})
+		return
+	}
+	if str := c.Query("hotel_id"); str != "" {
+		id, err := uuid.FromString(str)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad hotel ID"})
+			return
+		}
+		search.HotelID = &id
+	}
+	search.DataCenters = contextUUIDs(c, "dcList")
+	if str := c.Query("data_center_id"); str != "" {
+		id, err := uuid.FromString(str)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad data center ID"})
+			return
+		}
+		if !containsUUID(search.DataCenters, id) {
+			c.JSON(http.StatusOK, []dbmodels.RoomSearchResult{})
+			return
+		}
+		search.DataCenters = []uuid.UUID{id}
+	}
+	search.Type = c.Query("type")
+	if str := c.Query("party_size"); str != "" {
+		if search.PartySize, err = strconv.Atoi(str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid party_size"})
+			return
+		}
+	}
+	if str := c.Query("limit"); str != "" {
+		if search.Limit, err = strconv.Atoi(str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid limit"})
+			return
+		}
+	}
+
+	response, state, err := dbmodels.SearchRooms(search)
+	if err != nil {
+		log.Errorf("Error searching rooms %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// containsUUID tells whether the id is in the list, an empty list doesn't restrict anything
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostRoomBlockPAPI creates a room block of the provider
+func PostRoomBlockPAPI(c *gin.Context) {
+	var body dbmodels.RoomBlockPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.CreateRoomBlock(providerID, &body)
+	if err != nil {
+		log.Errorf("Error creating room block %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PutRoomBlockPAPI replaces a room block of the provider
+func PutRoomBlockPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad room block ID"})
+		return
+	}
+	var body dbmodels.RoomBlockPost
+	err = c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.UpdateRoomBlock(providerID, id, &body)
+	if err != nil {
+		log.Errorf("Error updating room block %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetRoomBlocksPAPI lists the room blocks of the provider
+func GetRoomBlocksPAPI(c *gin.Context) {
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, err := dbmodels.GetRoomBlocks(providerID)
+	if err != nil {
+		log.Errorf("Error listing room blocks %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteRoomBlockPAPI removes a room block of the provider
+func DeleteRoomBlockPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad room block ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	state, err := dbmodels.DeleteRoomBlock(providerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -20,21 +20,51 @@ import (
 var defaultLang = "en-GB"
 var myI18n = i18n.New(yaml.New("translations"))
 
-// GetBooking returns ...
-func GetBooking(c *gin.Context) {
+// GetBookingCAPI returns a booking of the customer
+func GetBookingCAPI(c *gin.Context) {
+	getBooking(c, dbmodels.RoleCustomer)
+}
+
+// GetBookingPAPI returns a booking of a room of the provider
+func GetBookingPAPI(c *gin.Context) {
+	getBooking(c, dbmodels.RoleProvider)
+}
+
+func getBooking(c *gin.Context, role string) {
 	acceptLang := c.GetHeader("Accept-Language")
+	if strings.HasSuffix(c.Param("id"), ".ics") {
+		getBookingICal(c, c.Param("id"), role)
+		return
+	}
 	bID, err := uuid.FromString(c.Param("id"))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
 	}
-	actor := c.MustGet("workflowActor").(string)
-	data, httpstatus, err := dbmodels.GetBooking(bID, actor)
+	fields, err := parseFields(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	embed, err := parseEmbed(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	ownerID := c.MustGet("customerID").(uuid.UUID)
+	data, httpstatus, err := dbmodels.GetBooking(bID, role, ownerID)
 	if err != nil {
 		c.AbortWithStatusJSON(httpstatus, gin.H{"message": err.Error()})
 		return
 	}
-	data.State = fmt.Sprint(myI18n.T(acceptLang, data.State))
-	c.JSON(http.StatusOK, *data)
+	bookings := []dbmodels.Booking{*data}
+	if err = dbmodels.EmbedResources(bookings, embed); err != nil {
+		log.Errorf("Error embedding resources: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	bookings[0].State = fmt.Sprint(myI18n.T(acceptLang, bookings[0].State))
+	renderBooking(c, http.StatusOK, bookings[0], fields)
 }
 
 // GetBookings returns ...
@@ -44,8 +74,24 @@ func GetBookings(c *gin.Context) {
 	perPage := c.MustGet("per_page").(int)
 	pageNumber := c.MustGet("page_number").(int)
 
-	actor := c.MustGet("workflowActor").(string)
-	data, total, err := dbmodels.GetBookings(actor)
+	fields, err := parseFields(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	embed, err := parseEmbed(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	filter, err := bookingFilter(c, dbmodels.RoleCustomer)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	data, total, err := dbmodels.GetBookings(filter, pageNumber, perPage)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid input value for enum") {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
@@ -55,17 +101,22 @@ func GetBookings(c *gin.Context) {
 		c.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
+	if err = dbmodels.EmbedResources(data, embed); err != nil {
+		log.Errorf("Error embedding resources: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
 	middlewares.WritePaginationHeaders(c, total)
 	for i := range data {
 		data[i].State = fmt.Sprint(myI18n.T(defaultLang, data[i].State))
 	}
-	c.JSON(http.StatusOK, dbmodels.BookingsResponse{
+	renderBookings(c, http.StatusOK, dbmodels.BookingsResponse{
 		Page:       pageNumber,
 		PerPage:    perPage,
 		NumResults: total,
 		Objects:    data,
-	})
+	}, fields)
 }
 
 // GetBookingsPAPI returns ...
@@ -80,22 +131,47 @@ func GetBookingsPAPI(c *gin.Context) {
 		pageNumber = p.(int)
 	}
 
-	actor := c.MustGet("workflowActor").(string)
-	data, total, err := dbmodels.GetBookings(actor)
+	filter, err := bookingFilter(c, dbmodels.RoleProvider)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if format := exportFormat(c); format != "" {
+		exportBookings(c, format, filter)
+		return
+	}
 
+	fields, err := parseFields(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	embed, err := parseEmbed(c)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
+	data, total, err := dbmodels.GetBookings(filter, pageNumber, perPage)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err = dbmodels.EmbedResources(data, embed); err != nil {
+		log.Errorf("Error embedding resources: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	middlewares.WritePaginationHeaders(c, total)
 
-	c.JSON(http.StatusOK, dbmodels.BookingsResponse{
+	renderBookings(c, http.StatusOK, dbmodels.BookingsResponse{
 		Page:       pageNumber,
 		PerPage:    perPage,
 		NumResults: total,
 		Objects:    data,
-	})
+	}, fields)
 }
 
 // PostBooking ...
@@ -169,7 +245,8 @@ func PatchBooking(c *gin.Context) {
 
 	var response *dbmodels.Booking
 
-	response, state, err := dbmodels.PatchBooking(&body, id)
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	response, state, err := dbmodels.PatchBookingScope(&body, id, c.Query("scope"), dbmodels.RoleCustomer, customerID)
 
 	if err != nil {
 		log.Errorln(err)
@@ -198,9 +275,12 @@ func PatchBookingPAPI(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
+	// the cancellations of the provider are free for the customer
+	body.WaiveCancellationFee = true
 	var response *dbmodels.Booking
 
-	response, state, err := dbmodels.PatchBooking(&body, id)
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, state, err := dbmodels.PatchBookingScope(&body, id, c.Query("scope"), dbmodels.RoleProvider, providerID)
 
 	if err != nil {
 		log.Errorf("\nError Patching FR %v \n", err)
@@ -266,3 +346,32 @@ func DeleteBookingSystem(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, "Deleted OK")
 }
+
+// PostBookingsBulkSAPI runs a batch of create, patch and delete operations
+func PostBookingsBulkSAPI(c *gin.Context) {
+	var body dbmodels.BulkRequest
+
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if len(body.Operations) == 0 || len(body.Operations) > dbmodels.MaxBulkOperations {
+		msg := fmt.Sprintf("between 1 and %d operations are accepted", dbmodels.MaxBulkOperations)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": msg})
+		return
+	}
+	atomic := c.Query("atomic") == "true"
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	requestorID := c.MustGet("UserID").(uuid.UUID)
+
+	response, err := dbmodels.RunBulk(body.Operations, atomic, customerID, requestorID)
+	if err != nil {
+		log.Errorf("Error running bulk request %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusMultiStatus, response)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostCancellationPolicyPAPI creates a cancellation policy of the provider
+func PostCancellationPolicyPAPI(c *gin.Context) {
+	var body dbmodels.CancellationPolicyPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.CreateCancellationPolicy(providerID, &body)
+	if err != nil {
+		log.Errorf("Error creating cancellation policy %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PutCancellationPolicyPAPI replaces a cancellation policy of the provider
+func PutCancellationPolicyPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad cancellation policy ID"})
+		return
+	}
+	var body dbmodels.CancellationPolicyPost
+	err = c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.UpdateCancellationPolicy(providerID, id, &body)
+	if err != nil {
+		log.Errorf("Error updating cancellation policy %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCancellationPoliciesPAPI lists the cancellation policies of the provider
+func GetCancellationPoliciesPAPI(c *gin.Context) {
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, err := dbmodels.GetCancellationPolicies(providerID)
+	if err != nil {
+		log.Errorf("Error listing cancellation policies %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteCancellationPolicyPAPI removes a cancellation policy of the provider
+func DeleteCancellationPolicyPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad cancellation policy ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	state, err := dbmodels.DeleteCancellationPolicy(providerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetCancellationPreview tells the customer what cancelling a booking now would cost
+func GetCancellationPreview(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	customerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetCancellationPreview(id, customerID)
+	if err != nil {
+		log.Errorf("Error previewing cancellation %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bookings/blobstore"
+	"bookings/dbmodels"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// allowedDocumentTypes are the accepted document content types, detected from the content
+var allowedDocumentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+}
+
+var documentStore blobstore.BlobStore
+var maxDocumentBytes int64 = 10 << 20
+
+// SetDocumentStore sets the store of the booking documents and their maximum size
+func SetDocumentStore(store blobstore.BlobStore, maxBytes int64) {
+	documentStore = store
+	if maxBytes > 0 {
+		maxDocumentBytes = maxBytes
+	}
+}
+
+// PostBookingDocumentCAPI uploads the document of a booking of the customer
+func PostBookingDocumentCAPI(c *gin.Context) {
+	postBookingDocument(c, dbmodels.RoleCustomer)
+}
+
+// PostBookingDocumentPAPI uploads the document of a booking of the provider
+func PostBookingDocumentPAPI(c *gin.Context) {
+	postBookingDocument(c, dbmodels.RoleProvider)
+}
+
+// GetBookingDocumentCAPI downloads the document of a booking of the customer
+func GetBookingDocumentCAPI(c *gin.Context) {
+	getBookingDocument(c, dbmodels.RoleCustomer)
+}
+
+// GetBookingDocumentPAPI downloads the document of a booking of the provider
+func GetBookingDocumentPAPI(c *gin.Context) {
+	getBookingDocument(c, dbmodels.RoleProvider)
+}
+
+// postBookingDocument uploads the document of a booking, it replaces the previous one
+func postBookingDocument(c *gin.Context, role string) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	// the booking is checked before the document is stored
+	if state, err := dbmodels.CheckBookingAccess(id, role, c.MustGet("customerID").(uuid.UUID)); err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	policy, state, err := dbmodels.GetDocumentPolicy(id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	if policy == dbmodels.DocumentUnavailable {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "The room of this booking does not accept documents"})
+		return
+	}
+
+	// leave room for the multipart envelope
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentBytes+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("multipart file field 'file' is mandatory: %s", err)})
+		return
+	}
+	defer file.Close()
+
+	stored, state, err := storeFile(c, file, header, fmt.Sprintf("bookings/%s/%s", id, uuid.NewV4()))
+	if err != nil {
+		if state == http.StatusInternalServerError {
+			log.Errorf("Error storing document of booking %s: %v", id, err)
+			c.AbortWithStatus(state)
+			return
+		}
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	doc := dbmodels.BookingDocument{
+		BookingID:   id,
+		Key:         stored.Key,
+		FileName:    stored.FileName,
+		ContentType: stored.ContentType,
+		Size:        stored.Size,
+		SHA256:      stored.SHA256,
+		UploadedAt:  time.Now().UTC(),
+	}
+
+	replaced, err := dbmodels.SaveBookingDocument(&doc)
+	if err != nil {
+		log.Errorf("Error saving document of booking %s: %v", id, err)
+		if derr := documentStore.Delete(c.Request.Context(), doc.Key); derr != nil {
+			log.Errorf("Error removing orphan document %s: %v", doc.Key, derr)
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if replaced != "" {
+		if err = documentStore.Delete(c.Request.Context(), replaced); err != nil {
+			log.Errorf("Error removing replaced document %s: %v", replaced, err)
+		}
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// storedFile is an uploaded file saved in the document store
+type storedFile struct {
+	Key         string
+	FileName    string
+	ContentType string
+	Size        int64
+	SHA256      string
+}
+
+// storeFile checks the size and the content type of the uploaded file and saves it in the
+// document store under the key
+func storeFile(c *gin.Context, file multipart.File, header *multipart.FileHeader, key string) (*storedFile, int, error) {
+	if header.Size > maxDocumentBytes {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("documents are limited to %d bytes", maxDocumentBytes)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, http.StatusBadRequest, err
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !allowedDocumentTypes[contentType] {
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("document type %s is not accepted", contentType)
+	}
+
+	hash := sha256.New()
+	content := io.TeeReader(io.MultiReader(bytes.NewReader(head), file), hash)
+	if err = documentStore.Put(c.Request.Context(), key, content, header.Size, contentType); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &storedFile{
+		Key:         key,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}, http.StatusOK, nil
+}
+
+// getBookingDocument downloads the document of a booking
+func getBookingDocument(c *gin.Context, role string) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	if state, err := dbmodels.CheckBookingAccess(id, role, c.MustGet("customerID").(uuid.UUID)); err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	doc, state, err := dbmodels.GetBookingDocument(id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	content, err := documentStore.Get(c.Request.Context(), doc.Key)
+	if err == blobstore.ErrNotFound {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": "document content not found"})
+		return
+	}
+	if err != nil {
+		log.Errorf("Error reading document of booking %s: %v", id, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, doc.Size, doc.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}),
+		"X-Checksum-Sha256":   doc.SHA256,
+	})
+}
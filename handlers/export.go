@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bookings/dbmodels"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Export content types
+const (
+	mimeCSV  = "text/csv"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var exportHeader = []string{
+	"id", "room_id", "customer_id", "requestor_id", "requested_at", "start_time", "end_time",
+	"state", "state_information", "description", "reference", "series_id", "party_size",
+	"total_price", "currency", "cancellation_fee",
+}
+
+// rowWriter writes a table row by row
+type rowWriter interface {
+	WriteRow(row []string) error
+	Close() error
+}
+
+// exportFormat returns the export content type requested in the Accept header, or an empty string for JSON
+func exportFormat(c *gin.Context) string {
+	accept := c.GetHeader("Accept")
+	switch {
+	case strings.Contains(accept, mimeCSV):
+		return mimeCSV
+	case strings.Contains(accept, mimeXLSX):
+		return mimeXLSX
+	}
+	return ""
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func bookingRow(b *dbmodels.Booking, lang string) []string {
+	seriesID := ""
+	if b.SeriesID != nil {
+		seriesID = b.SeriesID.String()
+	}
+	price := ""
+	if b.TotalPrice != nil {
+		price = fmt.Sprint(*b.TotalPrice)
+	}
+	fee := ""
+	if b.CancellationFee != nil {
+		fee = fmt.Sprint(*b.CancellationFee)
+	}
+	return []string{
+		b.ID.String(),
+		b.RoomID.String(),
+		b.CustomerID.String(),
+		b.RequestorID.String(),
+		b.RequestedAt.UTC().Format(time.RFC3339),
+		b.StartTime.UTC().Format(time.RFC3339),
+		b.EndTime.UTC().Format(time.RFC3339),
+		fmt.Sprint(myI18n.T(lang, "states."+b.State)),
+		optional(b.StateInfo),
+		optional(b.Description),
+		optional(b.Reference),
+		seriesID,
+		fmt.Sprint(b.PartySize),
+		price,
+		optional(b.Currency),
+		fee,
+	}
+}
+
+// exportBookings streams the bookings matching the filter as CSV or XLSX
+func exportBookings(c *gin.Context, format string, filter dbmodels.BookingFilter) {
+	lang := c.GetHeader("Accept-Language")
+	if lang == "" {
+		lang = defaultLang
+	}
+	w := newRowWriter(c, format, "bookings")
+	err := w.WriteRow(exportHeader)
+	if err == nil {
+		err = dbmodels.StreamBookings(filter, func(b *dbmodels.Booking) error {
+			return w.WriteRow(bookingRow(b, lang))
+		})
+	}
+	if err != nil {
+		// the status line is already sent, the truncated file is all we can do
+		log.Errorf("Error exporting bookings: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		log.Errorf("Error closing bookings export: %v", err)
+	}
+}
+
+// newRowWriter sends the headers of the CSV or XLSX attachment, named after the time of the
+// export, and returns its writer
+func newRowWriter(c *gin.Context, format, name string) rowWriter {
+	name += "-" + time.Now().UTC().Format("20060102150405")
+	var w rowWriter
+	if format == mimeXLSX {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, name))
+		c.Header("Content-Type", mimeXLSX)
+		w = newXLSXWriter(c.Writer)
+	} else {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+		w = &csvWriter{w: csv.NewWriter(c.Writer), flusher: c.Writer}
+	}
+	c.Status(http.StatusOK)
+	return w
+}
+
+// csvWriter flushes the rows to the client regularly
+type csvWriter struct {
+	w       *csv.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+func (w *csvWriter) WriteRow(row []string) error {
+	cells := make([]string, len(row))
+	for i, v := range row {
+		cells[i] = csvCell(v)
+	}
+	if err := w.w.Write(cells); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%500 == 0 {
+		w.w.Flush()
+		w.flusher.Flush()
+	}
+	return w.w.Error()
+}
+
+// csvCell quotes a cell a spreadsheet would read as a formula, the XLSX cells are inline
+// strings and are never evaluated
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	w.flusher.Flush()
+	return w.w.Error()
+}
+
+// xlsxWriter writes a single sheet workbook with inline strings, the sheet is streamed
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	rows  int
+	err   error
+}
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="bookings" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func newXLSXWriter(w io.Writer) *xlsxWriter {
+	x := &xlsxWriter{zw: zip.NewWriter(w)}
+	for _, part := range xlsxStaticParts {
+		var f io.Writer
+		if f, x.err = x.zw.Create(part.name); x.err != nil {
+			return x
+		}
+		if _, x.err = io.WriteString(f, part.content); x.err != nil {
+			return x
+		}
+	}
+	if x.sheet, x.err = x.zw.Create("xl/worksheets/sheet1.xml"); x.err != nil {
+		return x
+	}
+	_, x.err = io.WriteString(x.sheet, xml.Header+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return x
+}
+
+func (x *xlsxWriter) WriteRow(row []string) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.rows++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, x.rows)
+	for _, v := range row {
+		b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(&b, []byte(v))
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+	_, x.err = io.WriteString(x.sheet, b.String())
+	return x.err
+}
+
+func (x *xlsxWriter) Close() error {
+	if x.err == nil {
+		_, x.err = io.WriteString(x.sheet, `</sheetData></worksheet>`)
+	}
+	if err := x.zw.Close(); x.err == nil {
+		x.err = err
+	}
+	return x.err
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"encoding/csv"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qor/i18n"
+	"github.com/qor/i18n/backends/yaml"
+	uuid "github.com/satori/go.uuid"
+)
+
+func exportBooking(state string) *dbmodels.Booking {
+	return &dbmodels.Booking{
+		ID:          uuid.NewV4(),
+		RoomID:      uuid.NewV4(),
+		CustomerID:  uuid.NewV4(),
+		RequestorID: uuid.NewV4(),
+		RequestedAt: time.Date(2019, 1, 2, 9, 0, 0, 0, time.UTC),
+		StartTime:   time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC),
+		EndTime:     time.Date(2019, 1, 12, 10, 0, 0, 0, time.UTC),
+		State:       state,
+		PartySize:   1,
+	}
+}
+
+func exportColumn(name string) int {
+	for i, h := range exportHeader {
+		if h == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestBookingRowTranslatesTheState(t *testing.T) {
+	saved := myI18n
+	defer func() { myI18n = saved }()
+	myI18n = i18n.New(yaml.New(filepath.Join("..", "translations")))
+
+	row := bookingRow(exportBooking("pending_resp"), defaultLang)
+	if len(row) != len(exportHeader) {
+		t.Fatalf("row of %d cells, want %d", len(row), len(exportHeader))
+	}
+	if got := row[exportColumn("state")]; got != "Pending response" {
+		t.Errorf("state = %q, want the translated label", got)
+	}
+}
+
+func TestCSVWriterQuotesFormulas(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &csvWriter{w: csv.NewWriter(rec), flusher: rec}
+	row := []string{"=HYPERLINK(\"http://example.com\")", "+1", "-1", "@SUM(A1)", "\tx", "\rx", "plain", "a=b", ""}
+	if err := w.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := csv.NewReader(rec.Body).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"'=HYPERLINK(\"http://example.com\")", "'+1", "'-1", "'@SUM(A1)", "'\tx", "'\rx", "plain", "a=b", ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cell %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
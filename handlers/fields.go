@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bookingFields holds the json names of the Booking fields, they are the valid values of the fields parameter
+var bookingFields = jsonFieldNames(reflect.TypeOf(dbmodels.Booking{}))
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// queryList returns the comma separated values of a query parameter
+func queryList(c *gin.Context, name string) []string {
+	str, exists := c.GetQuery(name)
+	if !exists {
+		return nil
+	}
+	var res []string
+	for _, v := range strings.Split(str, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// parseFields returns the requested booking fields, nil means all of them
+func parseFields(c *gin.Context) ([]string, error) {
+	fields := queryList(c, "fields")
+	for _, f := range fields {
+		if !bookingFields[f] {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return fields, nil
+}
+
+// parseEmbed returns the resources to embed in the bookings
+func parseEmbed(c *gin.Context) ([]string, error) {
+	embed := queryList(c, "embed")
+	if err := dbmodels.CheckEmbed(embed); err != nil {
+		return nil, err
+	}
+	return embed, nil
+}
+
+// sparseBooking reduces the booking to the requested fields, the embedded resources are always kept
+func sparseBooking(b dbmodels.Booking, fields []string) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]json.RawMessage{}
+	if err = json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+	keep := append(append([]string{}, dbmodels.EmbeddableResources...), fields...)
+	res := map[string]json.RawMessage{}
+	for _, f := range keep {
+		if v, ok := all[f]; ok {
+			res[f] = v
+		}
+	}
+	return res, nil
+}
+
+// renderBooking writes a single booking honouring the fields parameter
+func renderBooking(c *gin.Context, status int, b dbmodels.Booking, fields []string) {
+	if len(fields) == 0 {
+		c.JSON(status, b)
+		return
+	}
+	res, err := sparseBooking(b, fields)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(status, res)
+}
+
+// renderBookings writes a bookings page honouring the fields parameter
+func renderBookings(c *gin.Context, status int, resp dbmodels.BookingsResponse, fields []string) {
+	if len(fields) == 0 {
+		c.JSON(status, resp)
+		return
+	}
+	objects := make([]map[string]json.RawMessage, 0, len(resp.Objects))
+	for _, b := range resp.Objects {
+		o, err := sparseBooking(b, fields)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		objects = append(objects, o)
+	}
+	c.JSON(status, gin.H{
+		"num_results": resp.NumResults,
+		"objects":     objects,
+		"page":        resp.Page,
+		"per_page":    resp.PerPage,
+	})
+}
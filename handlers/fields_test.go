@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+)
+
+func getBookingRequest(query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	id := uuid.NewV4().String()
+	c.Request = httptest.NewRequest("GET", "/booking_requests/"+id+query, nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("customerID", uuid.NewV4())
+	GetBookingCAPI(c)
+	return rec
+}
+
+func TestGetBookingRejectsAnUnknownField(t *testing.T) {
+	rec := getBookingRequest("?fields=id,nope")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `unknown field \"nope\"`) {
+		t.Errorf("got %d %s, want 400 for the unknown field", rec.Code, rec.Body)
+	}
+}
+
+func TestGetBookingRejectsAnUnknownEmbed(t *testing.T) {
+	rec := getBookingRequest("?embed=room,customer")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `unknown embedded resource \"customer\"`) {
+		t.Errorf("got %d %s, want 400 for the unknown embedded resource", rec.Code, rec.Body)
+	}
+}
+
+func TestRenderBookingSparse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	b := dbmodels.Booking{
+		ID:        uuid.NewV4(),
+		State:     "booked",
+		PartySize: 2,
+		Room:      &dbmodels.Room{ID: uuid.NewV4(), Name: "Blue"},
+	}
+	renderBooking(c, http.StatusOK, b, []string{"id", "state"})
+
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// the embedded resources are kept with the requested fields
+	for _, key := range []string{"id", "state", "room"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("the sparse booking lacks %q: %s", key, rec.Body)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("the sparse booking has %d fields, want 3: %s", len(got), rec.Body)
+	}
+	if string(got["state"]) != `"booked"` {
+		t.Errorf("state = %s", got["state"])
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+)
+
+const dateFormat = "2006-01-02"
+
+func contextUUIDs(c *gin.Context, key string) []uuid.UUID {
+	if v, exists := c.Get(key); exists {
+		if ids, ok := v.([]uuid.UUID); ok {
+			return ids
+		}
+	}
+	return nil
+}
+
+// bookingFilter builds the filter of the booking listings from the query parameters, scoped to
+// the bookings of the caller in the role
+func bookingFilter(c *gin.Context, role string) (dbmodels.BookingFilter, error) {
+	filter := dbmodels.BookingFilter{
+		Role:        role,
+		OwnerID:     c.MustGet("customerID").(uuid.UUID),
+		DataCenters: contextUUIDs(c, "dcList"),
+		Customers:   contextUUIDs(c, "csList"),
+		Requestors:  contextUUIDs(c, "rqList"),
+		States:      queryList(c, "states"),
+	}
+	for _, v := range queryList(c, "booking") {
+		id, err := uuid.FromString(v)
+		if err != nil {
+			return filter, fmt.Errorf("Booking ->%s<- is Not valid UUID", v)
+		}
+		filter.Bookings = append(filter.Bookings, id)
+	}
+	if str, exists := c.GetQuery("fromdate"); exists {
+		t, err := time.Parse(dateFormat, str)
+		if err != nil {
+			return filter, fmt.Errorf("invalid fromdate %q", str)
+		}
+		filter.FromDate = &t
+	}
+	if str, exists := c.GetQuery("todate"); exists {
+		t, err := time.Parse(dateFormat, str)
+		if err != nil {
+			return filter, fmt.Errorf("invalid todate %q", str)
+		}
+		// todate is inclusive
+		t = t.AddDate(0, 0, 1)
+		filter.ToDate = &t
+	}
+	return filter, nil
+}
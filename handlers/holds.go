@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostBookingHold reserves a slot for the customer until the hold is confirmed or expires
+func PostBookingHold(c *gin.Context) {
+	var body dbmodels.BookingPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	duration := dbmodels.DefaultHoldDuration
+	if str := c.Query("duration"); str != "" {
+		seconds, err := strconv.Atoi(str)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad hold duration"})
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	body.CustomerID = c.MustGet("customerID").(uuid.UUID)
+	body.RequestorID = c.MustGet("UserID").(uuid.UUID)
+
+	response, state, err := dbmodels.PostBookingHold(&body, duration)
+	if err != nil {
+		log.Errorf("Error holding booking %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PostBookingConfirm turns a hold of the customer into a booking request
+func PostBookingConfirm(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	var body dbmodels.BookingHoldConfirm
+	if c.Request.ContentLength != 0 {
+		if err := c.MustBindWith(&body, binding.JSON); err != nil {
+			log.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	customerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.ConfirmBookingHold(id, customerID, body.State)
+	if err != nil {
+		log.Errorf("Error confirming hold %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PatchHotelSAPI updates the time zone of a hotel
+func PatchHotelSAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad hotel ID"})
+		return
+	}
+	var body dbmodels.HotelPatch
+	err = c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	response, state, err := dbmodels.PatchHotel(id, &body)
+	if err != nil {
+		log.Errorf("Error updating hotel %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// icalStatus maps the booking states to the VEVENT STATUS values
+var icalStatus = map[string]string{
+	"draft":        "TENTATIVE",
+	"pending":      "TENTATIVE",
+	"pending_resp": "TENTATIVE",
+	"booked":       "CONFIRMED",
+	"completed":    "CONFIRMED",
+	"cancelled":    "CANCELLED",
+	"rejected":     "CANCELLED",
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// writeICalLine writes a content line folded at 75 octets as RFC 5545 requires
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// the continuation lines start with a space
+		limit = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func isUTF8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// renderICalendar creates a VCALENDAR with a VEVENT per booking
+func renderICalendar(bookings []dbmodels.Booking) []byte {
+	var buf bytes.Buffer
+	now := time.Now().UTC().Format(icalTimeFormat)
+	writeICalLine(&buf, "BEGIN:VCALENDAR")
+	writeICalLine(&buf, "VERSION:2.0")
+	writeICalLine(&buf, "PRODID:-//bookings//bookings API//EN")
+	writeICalLine(&buf, "CALSCALE:GREGORIAN")
+	for _, b := range bookings {
+		writeICalLine(&buf, "BEGIN:VEVENT")
+		writeICalLine(&buf, fmt.Sprintf("UID:%s@bookings", b.ID))
+		writeICalLine(&buf, "DTSTAMP:"+now)
+		writeICalLine(&buf, "DTSTART:"+b.StartTime.UTC().Format(icalTimeFormat))
+		writeICalLine(&buf, "DTEND:"+b.EndTime.UTC().Format(icalTimeFormat))
+		summary := "Booking"
+		if b.Room != nil {
+			summary = "Booking of " + b.Room.Name
+		}
+		writeICalLine(&buf, "SUMMARY:"+icalEscaper.Replace(summary))
+		location := []string{}
+		if b.Room != nil {
+			location = append(location, b.Room.Name)
+		}
+		if b.Hotel != nil {
+			location = append(location, b.Hotel.Name)
+		}
+		if len(location) > 0 {
+			writeICalLine(&buf, "LOCATION:"+icalEscaper.Replace(strings.Join(location, ", ")))
+		}
+		if b.Description != nil {
+			writeICalLine(&buf, "DESCRIPTION:"+icalEscaper.Replace(*b.Description))
+		}
+		if status, ok := icalStatus[b.State]; ok {
+			writeICalLine(&buf, "STATUS:"+status)
+		}
+		writeICalLine(&buf, "END:VEVENT")
+	}
+	writeICalLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// getBookingICal returns the booking as an iCalendar file
+func getBookingICal(c *gin.Context, id, role string) {
+	bID, err := uuid.FromString(strings.TrimSuffix(id, ".ics"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	ownerID := c.MustGet("customerID").(uuid.UUID)
+	data, httpstatus, err := dbmodels.GetBooking(bID, role, ownerID)
+	if err != nil {
+		c.AbortWithStatusJSON(httpstatus, gin.H{"message": err.Error()})
+		return
+	}
+	bookings := []dbmodels.Booking{*data}
+	if err = dbmodels.EmbedResources(bookings, []string{dbmodels.EmbedRoom, dbmodels.EmbedHotel}); err != nil {
+		log.Errorf("Error embedding resources: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", renderICalendar(bookings))
+}
+
+// GetCalendarFeed returns the iCalendar feed of the customer owning the token
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	bookings, httpstatus, err := dbmodels.GetCalendarFeed(token)
+	if err != nil {
+		if httpstatus == http.StatusInternalServerError {
+			log.Errorf("Error getting calendar feed: %v", err)
+		}
+		c.AbortWithStatusJSON(httpstatus, gin.H{"message": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", renderICalendar(bookings))
+}
+
+// PostCalendarToken creates a calendar feed token for the customer
+func PostCalendarToken(c *gin.Context) {
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	token, err := dbmodels.CreateCalendarToken(customerID)
+	if err != nil {
+		log.Errorf("Error creating calendar token: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// GetCalendarTokens lists the calendar feed tokens of the customer
+func GetCalendarTokens(c *gin.Context) {
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	tokens, err := dbmodels.GetCalendarTokens(customerID)
+	if err != nil {
+		log.Errorf("Error listing calendar tokens: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// DeleteCalendarToken revokes a calendar feed token of the customer
+func DeleteCalendarToken(c *gin.Context) {
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	httpstatus, err := dbmodels.RevokeCalendarToken(customerID, c.Param("token"))
+	if err != nil {
+		c.AbortWithStatusJSON(httpstatus, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriteICalLine(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+	tests := []struct {
+		name  string
+		line  string
+		lines []string
+	}{
+		{"short", "BEGIN:VEVENT", []string{"BEGIN:VEVENT"}},
+		{"75 octets", a(75), []string{a(75)}},
+		{"76 octets", a(76), []string{a(75), " a"}},
+		{"2 octet rune ending at 75", a(73) + "é", []string{a(73) + "é"}},
+		{"2 octet rune across 75", a(74) + "é", []string{a(74), " é"}},
+		{"3 octet rune across 75", a(74) + "€", []string{a(74), " €"}},
+		{"3 octet rune ending at 76", a(73) + "€", []string{a(73), " €"}},
+		{"4 octet rune across 75", a(72) + "😀", []string{a(72), " 😀"}},
+		{"4 octet rune ending at 75", a(71) + "😀", []string{a(71) + "😀"}},
+		{"continuation of 74 octets", a(75) + a(74) + "b", []string{a(75), " " + a(74), " b"}},
+		{"2 octet rune across the continuation limit", a(75) + a(73) + "é", []string{a(75), " " + a(73), " é"}},
+		{"2 octet rune ending at the continuation limit", a(75) + a(72) + "é", []string{a(75), " " + a(72) + "é"}},
+		{"runes only", strings.Repeat("é", 40), []string{strings.Repeat("é", 37), " " + strings.Repeat("é", 3)}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writeICalLine(&buf, tt.line)
+		out := buf.String()
+		want := strings.Join(tt.lines, "\r\n") + "\r\n"
+		if out != want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, out, want)
+		}
+		for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+			if len(l) > 75 {
+				t.Errorf("%s: line of %d octets", tt.name, len(l))
+			}
+			if !utf8.ValidString(l) {
+				t.Errorf("%s: line %q splits a rune", tt.name, l)
+			}
+		}
+		if unfolded := strings.Replace(strings.TrimSuffix(out, "\r\n"), "\r\n ", "", -1); unfolded != tt.line {
+			t.Errorf("%s: unfolds to %q", tt.name, unfolded)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"bookings/importer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostImportSAPI imports rooms or bookings from the CSV or JSON Lines request body
+func PostImportSAPI(c *gin.Context) {
+	opts := importer.Options{
+		Kind:        c.Query("kind"),
+		Format:      c.DefaultQuery("format", importer.FormatJSONL),
+		DryRun:      c.Query("dry_run") == "true",
+		CustomerID:  c.MustGet("customerID").(uuid.UUID),
+		RequestorID: c.MustGet("UserID").(uuid.UUID),
+	}
+	if opts.Kind != importer.KindRooms && opts.Kind != importer.KindBookings {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "kind must be 'rooms' or 'bookings'"})
+		return
+	}
+	if opts.Format != importer.FormatCSV && opts.Format != importer.FormatJSONL {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "format must be 'csv' or 'jsonl'"})
+		return
+	}
+
+	report, err := importer.Run(c.Request.Body, opts)
+	if err != nil {
+		log.Errorf("Error importing %s: %v", opts.Kind, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bookings/blobstore"
+	"bookings/dbmodels"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostBookingMessageCAPI adds a message of the customer to the thread of a booking
+func PostBookingMessageCAPI(c *gin.Context) {
+	postBookingMessage(c, dbmodels.MessageFromCustomer)
+}
+
+// PostBookingMessagePAPI adds a message of the provider to the thread of a booking
+func PostBookingMessagePAPI(c *gin.Context) {
+	postBookingMessage(c, dbmodels.MessageFromProvider)
+}
+
+// GetBookingMessagesCAPI lists the thread of a booking of the customer
+func GetBookingMessagesCAPI(c *gin.Context) {
+	getBookingMessages(c, dbmodels.MessageFromCustomer)
+}
+
+// GetBookingMessagesPAPI lists the thread of a booking of the provider
+func GetBookingMessagesPAPI(c *gin.Context) {
+	getBookingMessages(c, dbmodels.MessageFromProvider)
+}
+
+// GetBookingMessageAttachmentCAPI downloads the attachment of a message of a booking of the customer
+func GetBookingMessageAttachmentCAPI(c *gin.Context) {
+	getBookingMessageAttachment(c, dbmodels.MessageFromCustomer)
+}
+
+// GetBookingMessageAttachmentPAPI downloads the attachment of a message of a booking of the provider
+func GetBookingMessageAttachmentPAPI(c *gin.Context) {
+	getBookingMessageAttachment(c, dbmodels.MessageFromProvider)
+}
+
+// postBookingMessage reads the message as JSON, or as a multipart form with the 'body' field
+// and the optional 'file' attachment
+func postBookingMessage(c *gin.Context, role string) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	ownerID := c.MustGet("customerID").(uuid.UUID)
+	m := dbmodels.BookingMessage{
+		ID:         uuid.NewV4(),
+		BookingID:  id,
+		AuthorRole: role,
+		AuthorID:   c.MustGet("UserID").(uuid.UUID),
+	}
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		var body dbmodels.BookingMessagePost
+		if err = c.MustBindWith(&body, binding.JSON); err != nil {
+			log.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		m.Body = body.Body
+	} else {
+		// leave room for the multipart envelope and the body
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentBytes+1<<20)
+		m.Body = c.Request.FormValue("body")
+		file, header, err := c.Request.FormFile("file")
+		if err != nil && err != http.ErrMissingFile {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("bad multipart file field 'file': %s", err)})
+			return
+		}
+		if err == nil {
+			defer file.Close()
+			// the booking is checked before the attachment is stored
+			if state, err := dbmodels.CheckBookingAccess(id, role, ownerID); err != nil {
+				c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+				return
+			}
+			stored, state, err := storeFile(c, file, header, fmt.Sprintf("bookings/%s/messages/%s", id, m.ID))
+			if err != nil {
+				if state == http.StatusInternalServerError {
+					log.Errorf("Error storing attachment of booking %s: %v", id, err)
+					c.AbortWithStatus(state)
+					return
+				}
+				c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+				return
+			}
+			m.AttachmentKey = &stored.Key
+			m.AttachmentName = &stored.FileName
+			m.AttachmentType = &stored.ContentType
+			m.AttachmentSize = &stored.Size
+			m.AttachmentSHA256 = &stored.SHA256
+		}
+	}
+
+	state, err := dbmodels.PostBookingMessage(ownerID, &m)
+	if err != nil {
+		if m.AttachmentKey != nil {
+			if derr := documentStore.Delete(c.Request.Context(), *m.AttachmentKey); derr != nil {
+				log.Errorf("Error removing orphan attachment %s: %v", *m.AttachmentKey, derr)
+			}
+		}
+		log.Errorf("Error posting booking message %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+func getBookingMessages(c *gin.Context, role string) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	ownerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetBookingMessages(id, role, ownerID)
+	if err != nil {
+		log.Errorf("Error listing booking messages %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func getBookingMessageAttachment(c *gin.Context, role string) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	messageID, err := uuid.FromString(c.Param("message_id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad message ID"})
+		return
+	}
+	ownerID := c.MustGet("customerID").(uuid.UUID)
+
+	m, state, err := dbmodels.GetBookingMessage(id, messageID, role, ownerID)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	if m.AttachmentKey == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": fmt.Sprintf("message %s has no attachment", messageID)})
+		return
+	}
+	content, err := documentStore.Get(c.Request.Context(), *m.AttachmentKey)
+	if err == blobstore.ErrNotFound {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": "attachment content not found"})
+		return
+	}
+	if err != nil {
+		log.Errorf("Error reading attachment of message %s: %v", messageID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, *m.AttachmentSize, *m.AttachmentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": *m.AttachmentName}),
+		"X-Checksum-Sha256":   *m.AttachmentSHA256,
+	})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostBookingMovePAPI moves a booking to another room of the provider or swaps the rooms of two bookings
+func PostBookingMovePAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	var body dbmodels.BookingMove
+	err = c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	userID := c.MustGet("UserID").(uuid.UUID)
+
+	response, state, err := dbmodels.MoveBooking(providerID, userID, id, &body)
+	if err != nil {
+		log.Errorf("Error moving booking %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBookingMovesPAPI lists the room moves of a booking
+func GetBookingMovesPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetBookingMoves(id, providerID)
+	if err != nil {
+		log.Errorf("Error listing booking moves %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
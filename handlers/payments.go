@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"bookings/payments"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxCallbackBytes limits the payment callback bodies
+const maxCallbackBytes = 1 << 20
+
+var paymentManager *payments.Manager
+
+// SetPaymentManager sets the manager receiving the payment callbacks
+func SetPaymentManager(m *payments.Manager) {
+	paymentManager = m
+}
+
+// GetBookingPaymentCAPI returns the payment of a booking of the customer with its transactions
+func GetBookingPaymentCAPI(c *gin.Context) {
+	getBookingPayment(c, dbmodels.RoleCustomer)
+}
+
+// GetBookingPaymentPAPI returns the payment of a booking of the provider with its transactions
+func GetBookingPaymentPAPI(c *gin.Context) {
+	getBookingPayment(c, dbmodels.RoleProvider)
+}
+
+func getBookingPayment(c *gin.Context, role string) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad booking ID"})
+		return
+	}
+	ownerID := c.MustGet("customerID").(uuid.UUID)
+	if state, err := dbmodels.CheckBookingAccess(id, role, ownerID); err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	response, state, err := dbmodels.GetBookingPayment(id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PostPaymentCallback records the asynchronous result of a payment operation, the signature
+// of the body authenticates the provider
+func PostPaymentCallback(c *gin.Context) {
+	if paymentManager == nil {
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCallbackBytes))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	state, err := paymentManager.HandleCallback(c.Param("provider"), body, c.GetHeader(payments.SignatureHeader))
+	if err != nil {
+		log.Errorf("Error handling payment callback %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(state)
+}
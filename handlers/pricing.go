@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostRatePlanPAPI creates a rate plan of the provider
+func PostRatePlanPAPI(c *gin.Context) {
+	var body dbmodels.RatePlanPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.CreateRatePlan(providerID, &body)
+	if err != nil {
+		log.Errorf("Error creating rate plan %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PutRatePlanPAPI replaces a rate plan of the provider
+func PutRatePlanPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad rate plan ID"})
+		return
+	}
+	var body dbmodels.RatePlanPost
+	err = c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.UpdateRatePlan(providerID, id, &body)
+	if err != nil {
+		log.Errorf("Error updating rate plan %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetRatePlansPAPI lists the rate plans of the provider
+func GetRatePlansPAPI(c *gin.Context) {
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, err := dbmodels.GetRatePlans(providerID)
+	if err != nil {
+		log.Errorf("Error listing rate plans %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteRatePlanPAPI removes a rate plan of the provider
+func DeleteRatePlanPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad rate plan ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	state, err := dbmodels.DeleteRatePlan(providerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetRoomQuote prices a stay in a room before booking it
+func GetRoomQuote(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad room ID"})
+		return
+	}
+	start, err := time.Parse(time.RFC3339, c.Query("start_time"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid start_time, RFC 3339 time expected"})
+		return
+	}
+	end, err := time.Parse(time.RFC3339, c.Query("end_time"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid end_time, RFC 3339 time expected"})
+		return
+	}
+
+	response, state, err := dbmodels.GetQuote(id, start, end)
+	if err != nil {
+		log.Errorf("Error quoting room %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
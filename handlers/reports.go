@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultReportDays is the number of days reported when no start is given
+const defaultReportDays = 30
+
+var occupancyReportHeader = []string{
+	"period", "hotel_id", "room_id", "rooms", "bookings", "open_seconds", "blocked_seconds",
+	"bookable_seconds", "occupied_seconds", "occupancy",
+}
+
+var bookingStatsReportHeader = []string{
+	"hotel_id", "room_id", "total", "draft", "pending", "pending_resp", "booked", "completed", "rejected",
+	"cancelled", "cancellation_rate", "average_lead_seconds", "median_lead_seconds", "p90_lead_seconds",
+}
+
+var leadTimeReportHeader = []string{"hotel_id", "room_id", "min_days", "max_days", "bookings", "share"}
+
+// parseReportQuery reads the local dates, the hotel and the grouping of a report, the period
+// ends today by default
+func parseReportQuery(c *gin.Context) (dbmodels.ReportQuery, error) {
+	now := time.Now().UTC()
+	q := dbmodels.ReportQuery{
+		To:       time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC),
+		GroupBy:  c.Query("group_by"),
+		Interval: c.Query("interval"),
+	}
+	var err error
+	if str := c.Query("to"); str != "" {
+		if q.To, err = time.Parse("2006-01-02", str); err != nil {
+			return q, fmt.Errorf("invalid to, date expected")
+		}
+	}
+	q.From = q.To.AddDate(0, 0, -defaultReportDays)
+	if str := c.Query("from"); str != "" {
+		if q.From, err = time.Parse("2006-01-02", str); err != nil {
+			return q, fmt.Errorf("invalid from, date expected")
+		}
+	}
+	if str := c.Query("hotel_id"); str != "" {
+		id, err := uuid.FromString(str)
+		if err != nil {
+			return q, fmt.Errorf("invalid hotel_id, uuid expected")
+		}
+		q.HotelID = &id
+	}
+	return q, nil
+}
+
+// writeReport sends the rows of the report as CSV or XLSX
+func writeReport(c *gin.Context, format, name string, header []string, rows [][]string) {
+	w := newRowWriter(c, format, name)
+	err := w.WriteRow(header)
+	for i := 0; err == nil && i < len(rows); i++ {
+		err = w.WriteRow(rows[i])
+	}
+	if err != nil {
+		log.Errorf("Error exporting %s: %v", name, err)
+	}
+	if err = w.Close(); err != nil {
+		log.Errorf("Error closing %s export: %v", name, err)
+	}
+}
+
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// GetOccupancyReportPAPI reports the occupancy of the rooms of the provider per period
+func GetOccupancyReportPAPI(c *gin.Context) {
+	q, err := parseReportQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetOccupancyReport(providerID, q)
+	if err != nil {
+		log.Errorf("Error getting occupancy report %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	if format := exportFormat(c); format != "" {
+		rows := make([][]string, 0, len(response))
+		for _, r := range response {
+			rows = append(rows, []string{
+				r.Period,
+				r.HotelID.String(),
+				optionalUUID(r.RoomID),
+				fmt.Sprint(r.Rooms),
+				fmt.Sprint(r.Bookings),
+				fmt.Sprint(r.OpenSeconds),
+				fmt.Sprint(r.BlockedSeconds),
+				fmt.Sprint(r.BookableSeconds),
+				fmt.Sprint(r.OccupiedSeconds),
+				formatFloat(r.Occupancy),
+			})
+		}
+		writeReport(c, format, "occupancy", occupancyReportHeader, rows)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBookingStatsReportPAPI reports the booking requests of the provider by state
+func GetBookingStatsReportPAPI(c *gin.Context) {
+	q, err := parseReportQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetBookingStatsReport(providerID, q)
+	if err != nil {
+		log.Errorf("Error getting booking stats report %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	if format := exportFormat(c); format != "" {
+		rows := make([][]string, 0, len(response))
+		for _, r := range response {
+			rows = append(rows, []string{
+				r.HotelID.String(),
+				optionalUUID(r.RoomID),
+				fmt.Sprint(r.Total),
+				fmt.Sprint(r.Draft),
+				fmt.Sprint(r.Pending),
+				fmt.Sprint(r.PendingResp),
+				fmt.Sprint(r.Booked),
+				fmt.Sprint(r.Completed),
+				fmt.Sprint(r.Rejected),
+				fmt.Sprint(r.Cancelled),
+				formatFloat(r.CancellationRate),
+				formatFloat(r.AverageLeadSeconds),
+				formatFloat(r.MedianLeadSeconds),
+				formatFloat(r.P90LeadSeconds),
+			})
+		}
+		writeReport(c, format, "booking-stats", bookingStatsReportHeader, rows)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLeadTimeReportPAPI reports the distribution of the lead times of the booking requests of the provider
+func GetLeadTimeReportPAPI(c *gin.Context) {
+	q, err := parseReportQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.GetLeadTimeReport(providerID, q)
+	if err != nil {
+		log.Errorf("Error getting lead time report %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	if format := exportFormat(c); format != "" {
+		rows := make([][]string, 0, len(response))
+		for _, r := range response {
+			maxDays := ""
+			if r.MaxDays != nil {
+				maxDays = fmt.Sprint(*r.MaxDays)
+			}
+			rows = append(rows, []string{
+				r.HotelID.String(),
+				optionalUUID(r.RoomID),
+				fmt.Sprint(r.MinDays),
+				maxDays,
+				fmt.Sprint(r.Bookings),
+				formatFloat(r.Share),
+			})
+		}
+		writeReport(c, format, "lead-times", leadTimeReportHeader, rows)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
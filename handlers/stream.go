@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"bookings/events"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	streamBuffer      = 256
+	streamReplayBatch = 500
+	streamHeartbeat   = 20 * time.Second
+	streamPoll        = 2 * time.Second
+)
+
+// eventFilter selects the streamed events, with the parameters of the booking listing. Like
+// the listing of a provider, only the events of the rooms of the provider are streamed.
+type eventFilter struct {
+	provider    uuid.UUID
+	dataCenters map[uuid.UUID]bool
+	customers   map[uuid.UUID]bool
+	states      map[string]bool
+}
+
+func newEventFilter(c *gin.Context) eventFilter {
+	f := eventFilter{
+		provider:    c.MustGet("customerID").(uuid.UUID),
+		dataCenters: map[uuid.UUID]bool{},
+		customers:   map[uuid.UUID]bool{},
+		states:      map[string]bool{},
+	}
+	for _, id := range contextUUIDs(c, "dcList") {
+		f.dataCenters[id] = true
+	}
+	for _, id := range contextUUIDs(c, "csList") {
+		f.customers[id] = true
+	}
+	for _, s := range queryList(c, "states") {
+		f.states[s] = true
+	}
+	return f
+}
+
+func (f eventFilter) match(ev *dbmodels.RoomBookingEvent) bool {
+	if ev.Provider == nil || *ev.Provider != f.provider {
+		return false
+	}
+	if len(f.dataCenters) > 0 && (ev.DataCenterID == nil || !f.dataCenters[*ev.DataCenterID]) {
+		return false
+	}
+	if len(f.customers) > 0 && !f.customers[ev.CustomerID] {
+		return false
+	}
+	if len(f.states) > 0 && !f.states[ev.State] {
+		return false
+	}
+	return true
+}
+
+func writeEvent(c *gin.Context, ev *events.BookingEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", dbmodels.CursorOf(ev), ev.Type, data)
+	return err
+}
+
+// GetBookingsStreamPAPI streams the booking changes as Server-Sent Events. A client
+// resuming with Last-Event-ID gets the events it missed from the booking events log first.
+// The events are always read from the log in commit order, the published events only wake
+// the stream up. A provider only gets the events of its rooms, the catch-up included.
+func GetBookingsStreamPAPI(c *gin.Context) {
+	filter := newEventFilter(c)
+	var cursor dbmodels.EventCursor
+	var err error
+	if str := c.GetHeader("Last-Event-ID"); str != "" {
+		if cursor, err = dbmodels.ParseEventCursor(str); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "invalid Last-Event-ID"})
+			return
+		}
+	} else if cursor, err = dbmodels.GetBookingEventsHead(); err != nil {
+		log.Errorf("Error reading the booking events head: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// subscribe before the replay, so no event falls between the two
+	sub := events.Listen(streamBuffer)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+	c.Writer.Flush()
+
+	// send writes the events of the log following the cursor
+	send := func() bool {
+		for {
+			evs, err := dbmodels.GetRoomBookingEventsAfter(cursor, streamReplayBatch)
+			if err != nil {
+				log.Errorf("Error reading booking events: %v", err)
+				return false
+			}
+			for i := range evs {
+				cursor = dbmodels.CursorOf(&evs[i].BookingEvent)
+				if !filter.match(&evs[i]) {
+					continue
+				}
+				if err = writeEvent(c, &evs[i].BookingEvent); err != nil {
+					return false
+				}
+			}
+			c.Writer.Flush()
+			if len(evs) < streamReplayBatch {
+				return true
+			}
+		}
+	}
+	if !send() {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	// releases the events held back by an older running transaction
+	poll := time.NewTicker(streamPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-poll.C:
+			if !send() {
+				return
+			}
+		case _, ok := <-sub.C:
+			if !ok {
+				// the client fell behind, it reconnects and resumes from the log
+				return
+			}
+			if !send() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"bookings/events"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+)
+
+func streamContext(providerID uuid.UUID, query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/provider/booking_requests/stream"+query, nil)
+	c.Set("customerID", providerID)
+	return c
+}
+
+func roomEvent(provider *uuid.UUID, state string) *dbmodels.RoomBookingEvent {
+	return &dbmodels.RoomBookingEvent{
+		BookingEvent: events.BookingEvent{
+			Type:       events.BookingUpdated,
+			BookingID:  uuid.NewV4(),
+			CustomerID: uuid.NewV4(),
+			RoomID:     uuid.NewV4(),
+			State:      state,
+		},
+		Provider: provider,
+	}
+}
+
+func TestEventFilterScopesToTheRoomsOfTheProvider(t *testing.T) {
+	providerA, providerB := uuid.NewV4(), uuid.NewV4()
+	ev := roomEvent(&providerA, "booked")
+
+	if !newEventFilter(streamContext(providerA, "")).match(ev) {
+		t.Error("provider A does not get the event of its room")
+	}
+	if newEventFilter(streamContext(providerB, "")).match(ev) {
+		t.Error("provider B gets the event of a room of provider A")
+	}
+	if newEventFilter(streamContext(providerB, "?states=booked")).match(ev) {
+		t.Error("provider B gets the event of a room of provider A with a state filter")
+	}
+	if newEventFilter(streamContext(providerA, "")).match(roomEvent(nil, "booked")) {
+		t.Error("the event of a room without provider is streamed")
+	}
+}
+
+func TestEventFilterStates(t *testing.T) {
+	provider := uuid.NewV4()
+	f := newEventFilter(streamContext(provider, "?states=booked,cancelled"))
+	if !f.match(roomEvent(&provider, "cancelled")) {
+		t.Error("the event of a listed state is filtered out")
+	}
+	if f.match(roomEvent(&provider, "requested")) {
+		t.Error("the event of an unlisted state is streamed")
+	}
+}
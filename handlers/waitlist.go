@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostWaitlistEntry puts the customer on the waitlist of a room
+func PostWaitlistEntry(c *gin.Context) {
+	var body dbmodels.WaitlistEntryPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	requestorID := c.MustGet("UserID").(uuid.UUID)
+
+	response, state, err := dbmodels.JoinWaitlist(customerID, requestorID, &body)
+	if err != nil {
+		log.Errorf("Error joining waitlist %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetWaitlistEntries lists the waitlist entries of the customer
+func GetWaitlistEntries(c *gin.Context) {
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	response, err := dbmodels.GetWaitlistEntries(customerID)
+	if err != nil {
+		log.Errorf("Error listing waitlist entries %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWaitlistEntry takes the customer off a waitlist
+func DeleteWaitlistEntry(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad waitlist entry ID"})
+		return
+	}
+	customerID := c.MustGet("customerID").(uuid.UUID)
+	state, err := dbmodels.LeaveWaitlist(customerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+
+	middlewares "git.ntteo.net/go-libs.git/gin-middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostWebhookPAPI registers a webhook endpoint of the provider
+func PostWebhookPAPI(c *gin.Context) {
+	var body dbmodels.WebhookEndpointPost
+	err := c.MustBindWith(&body, binding.JSON)
+	if err != nil {
+		log.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	response, state, err := dbmodels.CreateWebhookEndpoint(providerID, &body)
+	if err != nil {
+		log.Errorf("Error creating webhook endpoint %v", err)
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetWebhooksPAPI lists the webhook endpoints of the provider
+func GetWebhooksPAPI(c *gin.Context) {
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, err := dbmodels.GetWebhookEndpoints(providerID)
+	if err != nil {
+		log.Errorf("Error listing webhook endpoints %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhookPAPI removes a webhook endpoint of the provider
+func DeleteWebhookPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad webhook ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	state, err := dbmodels.DeleteWebhookEndpoint(providerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveriesPAPI returns the delivery log of a webhook endpoint
+func GetWebhookDeliveriesPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad webhook ID"})
+		return
+	}
+	status := c.Query("status")
+	if status != "" && status != dbmodels.DeliveryPending && status != dbmodels.DeliveryDelivered && status != dbmodels.DeliveryFailed {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "status must be 'pending', 'delivered' or 'failed'"})
+		return
+	}
+	perPage := c.MustGet("per_page").(int)
+	pageNumber := c.MustGet("page_number").(int)
+	providerID := c.MustGet("customerID").(uuid.UUID)
+
+	data, total, err := dbmodels.GetWebhookDeliveries(providerID, id, status, pageNumber, perPage)
+	if err != nil {
+		log.Errorf("Error listing webhook deliveries %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	middlewares.WritePaginationHeaders(c, total)
+	c.JSON(http.StatusOK, gin.H{
+		"num_results": total,
+		"objects":     data,
+		"page":        pageNumber,
+		"per_page":    perPage,
+	})
+}
+
+// PostWebhookReplayPAPI sends a webhook delivery again
+func PostWebhookReplayPAPI(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Bad delivery ID"})
+		return
+	}
+	providerID := c.MustGet("customerID").(uuid.UUID)
+	response, state, err := dbmodels.ReplayWebhookDelivery(providerID, id)
+	if err != nil {
+		c.AbortWithStatusJSON(state, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, response)
+}
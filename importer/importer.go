@@ -0,0 +1,230 @@
+package importer
+
+import (
+	"bookings/dbmodels"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Kinds of imported records
+const (
+	KindRooms    = "rooms"
+	KindBookings = "bookings"
+)
+
+// Formats of the imported files
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Options of an import
+type Options struct {
+	Kind   string
+	Format string
+	DryRun bool
+	// CustomerID and RequestorID are used for the bookings which have none
+	CustomerID  uuid.UUID
+	RequestorID uuid.UUID
+}
+
+// LineError is the failure of a single line
+type LineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// Report summarizes an import
+type Report struct {
+	Kind     string      `json:"kind"`
+	Format   string      `json:"format"`
+	DryRun   bool        `json:"dry_run"`
+	Total    int         `json:"total"`
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Errors   []LineError `json:"errors"`
+}
+
+// FormatFromName guesses the format from a file name
+func FormatFromName(name string) string {
+	if strings.HasSuffix(name, ".csv") {
+		return FormatCSV
+	}
+	return FormatJSONL
+}
+
+// Run imports the records read from r. Every record is validated and written on its
+// own, the failing ones are reported by line. Nothing is written in dry-run mode.
+func Run(r io.Reader, opts Options) (*Report, error) {
+	var recordType reflect.Type
+	switch opts.Kind {
+	case KindRooms:
+		recordType = reflect.TypeOf(dbmodels.Room{})
+	case KindBookings:
+		recordType = reflect.TypeOf(dbmodels.BookingPost{})
+	default:
+		return nil, fmt.Errorf("unknown import kind %q", opts.Kind)
+	}
+
+	var next func() (int, []byte, error)
+	switch opts.Format {
+	case FormatCSV:
+		next = csvRecords(r, recordType)
+	case FormatJSONL:
+		next = jsonlRecords(r)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", opts.Format)
+	}
+
+	im, err := dbmodels.NewImporter()
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Kind: opts.Kind, Format: opts.Format, DryRun: opts.DryRun, Errors: []LineError{}}
+	for {
+		line, record, err := next()
+		if err == io.EOF {
+			break
+		}
+		report.Total++
+		if err == nil {
+			err = importRecord(im, opts, record)
+		}
+		if err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, LineError{Line: line, Message: err.Error()})
+			continue
+		}
+		report.Imported++
+	}
+
+	if err = im.Finish(!opts.DryRun); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func importRecord(im *dbmodels.Importer, opts Options, record []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.DisallowUnknownFields()
+	if opts.Kind == KindRooms {
+		var room dbmodels.Room
+		if err := dec.Decode(&room); err != nil {
+			return err
+		}
+		_, err := im.Room(&room)
+		return err
+	}
+
+	var body dbmodels.BookingPost
+	if err := dec.Decode(&body); err != nil {
+		return err
+	}
+	if body.CustomerID == uuid.Nil {
+		body.CustomerID = opts.CustomerID
+	}
+	if body.RequestorID == uuid.Nil {
+		body.RequestorID = opts.RequestorID
+	}
+	_, _, err := im.Booking(&body)
+	return err
+}
+
+// jsonlRecords returns the non empty lines of a JSON Lines file
+func jsonlRecords(r io.Reader) func() (int, []byte, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	return func() (int, []byte, error) {
+		for scanner.Scan() {
+			line++
+			if text := bytes.TrimSpace(scanner.Bytes()); len(text) > 0 {
+				return line, append([]byte{}, text...), nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			line++
+			return line, nil, err
+		}
+		return line, nil, io.EOF
+	}
+}
+
+// csvRecords converts the rows of a CSV file with a header of json field names to JSON objects
+func csvRecords(r io.Reader, t reflect.Type) func() (int, []byte, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var header []string
+	var headerErr error
+	line := 1
+	header, headerErr = reader.Read()
+	kinds := jsonKinds(t)
+	return func() (int, []byte, error) {
+		if headerErr != nil {
+			err := headerErr
+			headerErr = io.EOF
+			return line, nil, err
+		}
+		row, err := reader.Read()
+		if err == io.EOF {
+			return line, nil, io.EOF
+		}
+		line++
+		if err != nil {
+			return line, nil, err
+		}
+		if len(row) != len(header) {
+			return line, nil, fmt.Errorf("expected %d columns, got %d", len(header), len(row))
+		}
+		obj := map[string]json.RawMessage{}
+		for i, name := range header {
+			name = strings.TrimSpace(name)
+			value := strings.TrimSpace(row[i])
+			kind, ok := kinds[name]
+			if !ok {
+				return line, nil, fmt.Errorf("unknown column %q", name)
+			}
+			if value == "" {
+				continue
+			}
+			switch kind {
+			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				obj[name] = json.RawMessage(value)
+			case reflect.Slice:
+				items, _ := json.Marshal(strings.Split(value, "|"))
+				obj[name] = items
+			default:
+				quoted, _ := json.Marshal(value)
+				obj[name] = quoted
+			}
+		}
+		record, err := json.Marshal(obj)
+		return line, record, err
+	}
+}
+
+// jsonKinds maps the json field names of a struct to the kinds of their values
+func jsonKinds(t reflect.Type) map[string]reflect.Kind {
+	kinds := map[string]reflect.Kind{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		kinds[name] = ft.Kind()
+	}
+	return kinds
+}
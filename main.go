@@ -1,11 +1,22 @@
 package main
 
 import (
+	"bookings/blobstore"
 	"bookings/dbmodels"
+	"bookings/handlers"
+	"bookings/importer"
+	"bookings/notifier"
+	"bookings/payments"
+	"bookings/scheduler"
 	"bookings/server"
+	"bookings/waitlist"
+	"bookings/webhooks"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/davecgh/go-spew/spew"
 	log "github.com/sirupsen/logrus"
@@ -19,6 +30,13 @@ type Config struct {
 	MaxCompressedMessageBytes int      `envconfig:"max_compressed_message_bytes" default:"5000000"`
 	KafkaLoggingLevel         string   `envconfig:"kafka_logging_level" default:"warning"`
 	KafkaAuditTopic           string   `default:"history"`
+	DocumentStore             blobstore.Config
+	MaxDocumentBytes          int64 `envconfig:"max_document_bytes" default:"10485760"`
+	Notifier                  notifier.Config
+	Webhooks                  webhooks.Config
+	Scheduler                 scheduler.Config
+	Waitlist                  waitlist.Config
+	Payments                  payments.Config
 }
 
 const (
@@ -32,6 +50,11 @@ func main() {
 	var swaggerpapi = flag.Bool("swaggerpapi", false, "generate swagger json")
 	var swaggersapi = flag.Bool("swaggersapi", false, "generate swagger json")
 	var migrate = flag.Bool("migrate", false, "do db migration")
+	var importFile = flag.String("import", "", "import rooms or bookings from a CSV or JSON Lines file")
+	var importKind = flag.String("import-kind", importer.KindBookings, "kind of the imported records: rooms or bookings")
+	var importFormat = flag.String("import-format", "", "format of the imported file: csv or jsonl, guessed from the file name by default")
+	var dryRun = flag.Bool("dry-run", false, "validate the imported records without writing them")
+	flag.Parse()
 	conf.PostgresConfig = dbmodels.Config{
 		DBName:   "bookings",
 		Host:     "localhost",
@@ -39,6 +62,50 @@ func main() {
 		Port:     5432,
 		User:     "bookings",
 	}
+	conf.DocumentStore = blobstore.Config{
+		Backend:     getenv("DOCUMENT_STORE", "local"),
+		Dir:         getenv("DOCUMENT_DIR", "documents"),
+		S3Endpoint:  os.Getenv("DOCUMENT_S3_ENDPOINT"),
+		S3Region:    getenv("DOCUMENT_S3_REGION", "us-east-1"),
+		S3Bucket:    os.Getenv("DOCUMENT_S3_BUCKET"),
+		S3AccessKey: os.Getenv("DOCUMENT_S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("DOCUMENT_S3_SECRET_KEY"),
+		S3PathStyle: getenv("DOCUMENT_S3_PATH_STYLE", "true") == "true",
+	}
+	conf.MaxDocumentBytes = 10 << 20
+	conf.Notifier = notifier.Config{
+		SMTPAddr:    "localhost:25",
+		From:        "bookings@localhost",
+		Lang:        "en-GB",
+		Workers:     2,
+		MaxAttempts: 5,
+		QueueSize:   1000,
+	}
+	conf.Webhooks = webhooks.Config{
+		PollInterval: 2 * time.Second,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     6 * time.Hour,
+		BatchSize:    20,
+	}
+	conf.Scheduler = scheduler.Config{
+		HoldSweepInterval:  30 * time.Second,
+		HoldSweepBatch:     100,
+		CompletionInterval: 5 * time.Minute,
+		CompletionBatch:    500,
+		StalePendingState:  "rejected",
+		ApprovalInterval:   time.Minute,
+		ApprovalBatch:      100,
+	}
+	conf.Waitlist = waitlist.Config{
+		OfferDuration: 30 * time.Minute,
+	}
+	conf.Payments = payments.Config{
+		Provider:       payments.FakeName,
+		CallbackSecret: os.Getenv("PAYMENT_CALLBACK_SECRET"),
+		Timeout:        30 * time.Second,
+	}
 	if *swaggercapi {
 		api := server.CreateSwaggerCAPI()
 		sw, _ := api.RenderJSON()
@@ -66,10 +133,76 @@ func main() {
 		dbmodels.Migrate(database, "migrations")
 		os.Exit(0)
 	}
+	if *importFile != "" {
+		os.Exit(runImport(*importFile, *importKind, *importFormat, *dryRun))
+	}
+
+	store, err := blobstore.New(conf.DocumentStore)
+	if err != nil {
+		log.Fatalf("Failed to create document store: %s", err)
+	}
+	handlers.SetDocumentStore(store, conf.MaxDocumentBytes)
+
+	listener, err := dbmodels.ListenBookingEvents(conf.PostgresConfig)
+	if err != nil {
+		log.Fatalf("Failed to listen to booking events: %s", err)
+	}
+
+	mailer := notifier.New(conf.Notifier, notifier.NewSMTPSender(conf.Notifier))
+	mailer.Start()
+	waitlist.New(conf.Waitlist, mailer).Start()
+	payer, err := payments.New(conf.Payments)
+	if err != nil {
+		log.Fatalf("Failed to create the payment manager: %s", err)
+	}
+	handlers.SetPaymentManager(payer)
+	payer.Start()
+	webhooks.New(conf.Webhooks).Start()
+	jobs, err := scheduler.New(conf.Scheduler)
+	if err != nil {
+		log.Fatalf("Failed to create the scheduler: %s", err)
+	}
+	jobs.Start()
 
 	log.Info("Starting up Bookings API ...")
 	server.RunServer()
+	jobs.Stop()
+	payer.Stop()
+	listener.Close()
 
 	log.Info("Shutting Down")
 	os.Exit(0)
 }
+
+// getenv returns the value of the environment variable, or def when it isn't set
+func getenv(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+// runImport imports the file and prints the report, it returns the exit code
+func runImport(path, kind, format string, dryRun bool) int {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Errorf("Failed to open import file: %s", err)
+		return 1
+	}
+	defer f.Close()
+	if format == "" {
+		format = importer.FormatFromName(path)
+	}
+
+	report, err := importer.Run(f, importer.Options{Kind: kind, Format: format, DryRun: dryRun})
+	if err != nil {
+		log.Errorf("Failed to import %s: %s", kind, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	if report.Failed > 0 {
+		return 2
+	}
+	return 0
+}
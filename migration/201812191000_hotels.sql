@@ -0,0 +1,11 @@
+CREATE TABLE hotels(
+    id                      UUID PRIMARY KEY,
+    name                    TEXT NOT NULL,
+    data_center_id          UUID NOT NULL,
+    description             TEXT,
+    UNIQUE(name, data_center_id)
+);
+ALTER TABLE hotels OWNER TO bookings ;
+
+ALTER TABLE rooms ADD COLUMN type roomtype;
+ALTER TABLE rooms ADD COLUMN shared_nr_person SMALLINT;
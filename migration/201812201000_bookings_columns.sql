@@ -0,0 +1,2 @@
+ALTER TABLE bookings RENAME COLUMN room TO room_id;
+ALTER TABLE bookings ADD COLUMN reference TEXT;
@@ -0,0 +1,11 @@
+CREATE TABLE booking_series(
+    id          UUID PRIMARY KEY,
+    rrule       TEXT NOT NULL,
+    exdates     TIMESTAMP[],
+    created_at  TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE booking_series OWNER TO bookings ;
+
+ALTER TABLE bookings ADD COLUMN series_id UUID REFERENCES booking_series;
+CREATE INDEX bookings_series_id_idx ON bookings(series_id);
+CREATE INDEX bookings_room_id_time_idx ON bookings(room_id, start_time, end_time);
@@ -0,0 +1,8 @@
+CREATE TABLE calendar_tokens(
+    token       TEXT PRIMARY KEY,
+    customer_id UUID NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT now(),
+    revoked_at  TIMESTAMP
+);
+ALTER TABLE calendar_tokens OWNER TO bookings ;
+CREATE INDEX calendar_tokens_customer_id_idx ON calendar_tokens(customer_id);
@@ -0,0 +1 @@
+ALTER TABLE rooms ALTER COLUMN type_id DROP NOT NULL;
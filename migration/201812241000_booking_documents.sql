@@ -0,0 +1,10 @@
+CREATE TABLE booking_documents(
+    booking_id      UUID PRIMARY KEY REFERENCES bookings ON DELETE CASCADE,
+    storage_key     TEXT NOT NULL,
+    file_name       TEXT NOT NULL,
+    content_type    TEXT NOT NULL,
+    size            BIGINT NOT NULL,
+    sha256          TEXT NOT NULL,
+    uploaded_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE booking_documents OWNER TO bookings ;
@@ -0,0 +1,2 @@
+ALTER TABLE bookings ADD COLUMN booking_request_email TEXT;
+ALTER TABLE bookings ADD COLUMN booking_request_from_email TEXT;
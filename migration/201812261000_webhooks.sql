@@ -0,0 +1,31 @@
+CREATE TABLE webhook_endpoints(
+    id              UUID PRIMARY KEY,
+    provider_id     UUID NOT NULL,
+    url             TEXT NOT NULL,
+    secret          TEXT NOT NULL,
+    event_types     TEXT[] NOT NULL DEFAULT '{}',
+    active          BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE webhook_endpoints OWNER TO bookings ;
+CREATE INDEX webhook_endpoints_provider_id_idx ON webhook_endpoints(provider_id);
+
+CREATE TYPE deliverystatus AS ENUM ('pending', 'delivered', 'failed');
+ALTER TYPE deliverystatus OWNER TO bookings;
+
+CREATE TABLE webhook_deliveries(
+    id                  UUID PRIMARY KEY,
+    endpoint_id         UUID NOT NULL REFERENCES webhook_endpoints ON DELETE CASCADE,
+    event_type          TEXT NOT NULL,
+    payload             JSONB NOT NULL,
+    status              deliverystatus NOT NULL DEFAULT 'pending',
+    attempts            INTEGER NOT NULL DEFAULT 0,
+    last_status_code    INTEGER,
+    last_error          TEXT,
+    next_attempt_at     TIMESTAMP NOT NULL DEFAULT now(),
+    created_at          TIMESTAMP NOT NULL DEFAULT now(),
+    delivered_at        TIMESTAMP
+);
+ALTER TABLE webhook_deliveries OWNER TO bookings ;
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries(endpoint_id, created_at);
@@ -0,0 +1,14 @@
+CREATE TABLE booking_events(
+    id              BIGSERIAL PRIMARY KEY,
+    type            TEXT NOT NULL,
+    booking_id      UUID NOT NULL,
+    customer_id     UUID NOT NULL,
+    room_id         UUID NOT NULL,
+    data_center_id  UUID,
+    state           TEXT NOT NULL,
+    previous_state  TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    booking         JSONB NOT NULL
+);
+ALTER TABLE booking_events OWNER TO bookings ;
+CREATE INDEX booking_events_booking_id_idx ON booking_events(booking_id);
@@ -0,0 +1,2 @@
+ALTER TABLE bookings ADD COLUMN expires_at TIMESTAMP;
+CREATE INDEX bookings_expires_at_idx ON bookings(expires_at) WHERE state = 'draft' AND expires_at IS NOT NULL;
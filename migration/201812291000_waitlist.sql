@@ -0,0 +1,17 @@
+CREATE TABLE waitlist_entries(
+    id              UUID PRIMARY KEY,
+    room_id         UUID NOT NULL REFERENCES rooms ON DELETE CASCADE,
+    customer_id     UUID NOT NULL,
+    requestor_id    UUID NOT NULL,
+    start_time      TIMESTAMP NOT NULL,
+    end_time        TIMESTAMP NOT NULL,
+    email           TEXT,
+    description     TEXT,
+    status          TEXT NOT NULL DEFAULT 'waiting',
+    booking_id      UUID REFERENCES bookings ON DELETE SET NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    offered_at      TIMESTAMP
+);
+ALTER TABLE waitlist_entries OWNER TO bookings ;
+CREATE INDEX waitlist_entries_room_id_idx ON waitlist_entries(room_id, created_at) WHERE status = 'waiting';
+CREATE INDEX waitlist_entries_customer_id_idx ON waitlist_entries(customer_id);
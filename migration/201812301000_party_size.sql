@@ -0,0 +1,2 @@
+ALTER TABLE bookings ADD COLUMN party_size SMALLINT NOT NULL DEFAULT 1 CHECK (party_size > 0);
+ALTER TABLE waitlist_entries ADD COLUMN party_size SMALLINT NOT NULL DEFAULT 1 CHECK (party_size > 0);
@@ -0,0 +1,26 @@
+CREATE TYPE rateunit AS ENUM ('night', 'hour');
+ALTER TYPE rateunit OWNER TO bookings;
+
+CREATE TABLE rate_plans(
+    id              UUID PRIMARY KEY,
+    provider_id     UUID NOT NULL,
+    hotel_id        UUID NOT NULL REFERENCES hotels ON DELETE CASCADE,
+    room_id         UUID REFERENCES rooms ON DELETE CASCADE,
+    room_type       roomtype,
+    name            TEXT NOT NULL,
+    currency        CHAR(3) NOT NULL,
+    unit            rateunit NOT NULL,
+    base_rate       BIGINT NOT NULL CHECK (base_rate >= 0),
+    weekend_rate    BIGINT CHECK (weekend_rate >= 0),
+    seasons         JSONB NOT NULL DEFAULT '[]',
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((room_id IS NULL) <> (room_type IS NULL))
+);
+ALTER TABLE rate_plans OWNER TO bookings ;
+CREATE UNIQUE INDEX rate_plans_room_id_idx ON rate_plans(room_id) WHERE room_id IS NOT NULL;
+CREATE UNIQUE INDEX rate_plans_room_type_idx ON rate_plans(hotel_id, room_type) WHERE room_type IS NOT NULL;
+
+ALTER TABLE bookings ADD COLUMN rate_plan_id UUID REFERENCES rate_plans ON DELETE SET NULL;
+ALTER TABLE bookings ADD COLUMN total_price BIGINT;
+ALTER TABLE bookings ADD COLUMN currency CHAR(3);
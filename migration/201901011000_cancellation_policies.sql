@@ -0,0 +1,18 @@
+CREATE TABLE cancellation_policies(
+    id                  UUID PRIMARY KEY,
+    provider_id         UUID NOT NULL,
+    hotel_id            UUID NOT NULL REFERENCES hotels ON DELETE CASCADE,
+    room_id             UUID REFERENCES rooms ON DELETE CASCADE,
+    name                TEXT NOT NULL,
+    free_until_hours    INTEGER NOT NULL CHECK (free_until_hours >= 0),
+    fee_percent         SMALLINT NOT NULL CHECK (fee_percent BETWEEN 0 AND 100),
+    created_at          TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE cancellation_policies OWNER TO bookings ;
+CREATE UNIQUE INDEX cancellation_policies_room_id_idx ON cancellation_policies(room_id) WHERE room_id IS NOT NULL;
+CREATE UNIQUE INDEX cancellation_policies_hotel_id_idx ON cancellation_policies(hotel_id) WHERE room_id IS NULL;
+
+ALTER TABLE bookings ADD COLUMN cancellation_policy_id UUID REFERENCES cancellation_policies ON DELETE SET NULL;
+ALTER TABLE bookings ADD COLUMN cancellation_fee BIGINT;
+ALTER TABLE bookings ADD COLUMN cancelled_at TIMESTAMP;
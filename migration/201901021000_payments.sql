@@ -0,0 +1,29 @@
+CREATE TABLE payments(
+    id              UUID PRIMARY KEY,
+    booking_id      UUID NOT NULL UNIQUE,
+    provider        TEXT NOT NULL,
+    reference       TEXT,
+    currency        CHAR(3) NOT NULL,
+    amount          BIGINT NOT NULL CHECK (amount >= 0),
+    captured        BIGINT NOT NULL DEFAULT 0,
+    refunded        BIGINT NOT NULL DEFAULT 0,
+    status          TEXT NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE payments OWNER TO bookings ;
+
+CREATE TABLE payment_transactions(
+    id              UUID PRIMARY KEY,
+    payment_id      UUID NOT NULL REFERENCES payments ON DELETE CASCADE,
+    operation       TEXT NOT NULL,
+    amount          BIGINT NOT NULL,
+    status          TEXT NOT NULL,
+    reference       TEXT,
+    message         TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE payment_transactions OWNER TO bookings ;
+CREATE INDEX payment_transactions_payment_id_idx ON payment_transactions(payment_id);
+CREATE UNIQUE INDEX payment_transactions_reference_idx ON payment_transactions(reference) WHERE reference IS NOT NULL;
@@ -0,0 +1,16 @@
+-- the times stored so far are UTC ones
+SET TIME ZONE 'UTC';
+
+ALTER TABLE hotels ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE bookings
+    ALTER COLUMN requested_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN start_time TYPE TIMESTAMPTZ,
+    ALTER COLUMN end_time TYPE TIMESTAMPTZ,
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN cancelled_at TYPE TIMESTAMPTZ;
+ALTER TABLE booking_series ALTER COLUMN exdates TYPE TIMESTAMPTZ[];
+ALTER TABLE waitlist_entries
+    ALTER COLUMN start_time TYPE TIMESTAMPTZ,
+    ALTER COLUMN end_time TYPE TIMESTAMPTZ,
+    ALTER COLUMN offered_at TYPE TIMESTAMPTZ;
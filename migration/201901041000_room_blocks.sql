@@ -0,0 +1,16 @@
+CREATE TABLE room_blocks(
+    id                  UUID PRIMARY KEY,
+    provider_id         UUID NOT NULL,
+    hotel_id            UUID NOT NULL REFERENCES hotels ON DELETE CASCADE,
+    room_id             UUID REFERENCES rooms ON DELETE CASCADE,
+    kind                TEXT NOT NULL CHECK (kind IN ('blackout', 'maintenance')),
+    reason              TEXT,
+    start_time          TIMESTAMPTZ NOT NULL,
+    end_time            TIMESTAMPTZ NOT NULL CHECK (end_time > start_time),
+    rrule               TEXT,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+ALTER TABLE room_blocks OWNER TO bookings ;
+CREATE INDEX room_blocks_room_id_idx ON room_blocks(room_id, start_time) WHERE room_id IS NOT NULL;
+CREATE INDEX room_blocks_hotel_id_idx ON room_blocks(hotel_id, provider_id, start_time) WHERE room_id IS NULL;
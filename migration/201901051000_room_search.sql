@@ -0,0 +1,2 @@
+CREATE INDEX rooms_hotel_id_type_idx ON rooms(hotel_id, type);
+CREATE INDEX hotels_data_center_id_idx ON hotels(data_center_id);
@@ -0,0 +1,12 @@
+CREATE TABLE booking_moves(
+    id              UUID PRIMARY KEY,
+    booking_id      UUID NOT NULL REFERENCES bookings ON DELETE CASCADE,
+    from_room_id    UUID NOT NULL REFERENCES rooms,
+    to_room_id      UUID NOT NULL REFERENCES rooms,
+    swapped_with    UUID REFERENCES bookings ON DELETE SET NULL,
+    reason          TEXT,
+    moved_by        UUID NOT NULL,
+    moved_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+ALTER TABLE booking_moves OWNER TO bookings ;
+CREATE INDEX booking_moves_booking_id_idx ON booking_moves(booking_id, moved_at);
@@ -0,0 +1,21 @@
+CREATE TABLE approval_slas(
+    id                  UUID PRIMARY KEY,
+    provider_id         UUID NOT NULL,
+    hotel_id            UUID NOT NULL REFERENCES hotels ON DELETE CASCADE,
+    response_minutes    INTEGER NOT NULL CHECK (response_minutes > 0),
+    breach_action       TEXT NOT NULL CHECK (breach_action IN ('escalate', 'reject')),
+    escalation_email    TEXT,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE(hotel_id, provider_id)
+);
+ALTER TABLE approval_slas OWNER TO bookings ;
+
+ALTER TABLE bookings ADD COLUMN awaiting_since TIMESTAMPTZ;
+ALTER TABLE bookings ADD COLUMN escalated_at TIMESTAMPTZ;
+UPDATE bookings SET awaiting_since = requested_at WHERE state IN ('pending', 'pending_resp');
+CREATE INDEX bookings_awaiting_since_idx ON bookings(awaiting_since) WHERE state IN ('pending', 'pending_resp');
+
+-- the event times are UTC ones
+ALTER TABLE booking_events ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+CREATE INDEX booking_events_answers_idx ON booking_events(created_at) WHERE previous_state IN ('pending', 'pending_resp');
@@ -0,0 +1,16 @@
+CREATE TABLE booking_messages(
+    id                  UUID PRIMARY KEY,
+    booking_id          UUID NOT NULL REFERENCES bookings ON DELETE CASCADE,
+    author_role         TEXT NOT NULL CHECK (author_role IN ('customer', 'provider')),
+    author_id           UUID NOT NULL,
+    body                TEXT NOT NULL,
+    attachment_key      TEXT,
+    attachment_name     TEXT,
+    attachment_type     TEXT,
+    attachment_size     BIGINT,
+    attachment_sha256   TEXT,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    read_at             TIMESTAMPTZ
+);
+ALTER TABLE booking_messages OWNER TO bookings ;
+CREATE INDEX booking_messages_booking_id_idx ON booking_messages(booking_id, created_at);
@@ -0,0 +1 @@
+CREATE INDEX bookings_room_id_requested_at_idx ON bookings(room_id, requested_at);
@@ -0,0 +1,4 @@
+-- the transaction ID orders the events by commit: the events of a transaction older than the
+-- oldest running transaction can no longer appear before the ones already read
+ALTER TABLE booking_events ADD COLUMN tx_id BIGINT NOT NULL DEFAULT txid_current();
+CREATE INDEX booking_events_tx_id_id_idx ON booking_events(tx_id, id);
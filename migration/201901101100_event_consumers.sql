@@ -0,0 +1,9 @@
+-- the position of the consumers of the booking events log, a consumer is run by one instance at
+-- a time, the one holding its lease
+CREATE TABLE event_consumers(
+    name            TEXT PRIMARY KEY,
+    tx_id           BIGINT NOT NULL,
+    event_id        BIGINT NOT NULL,
+    leased_until    TIMESTAMPTZ
+);
+ALTER TABLE event_consumers OWNER TO bookings ;
@@ -0,0 +1,2 @@
+-- the instance holding the lease of a consumer, only it records the position of the consumer
+ALTER TABLE event_consumers ADD COLUMN owner UUID;
@@ -0,0 +1,4 @@
+-- a transaction is in flight from its record until the provider answered, the transactions
+-- interrupted before are sent again with the same idempotency key
+UPDATE payment_transactions SET status = 'in_flight' WHERE status = 'pending' AND reference IS NULL;
+CREATE INDEX payment_transactions_in_flight_idx ON payment_transactions(created_at) WHERE status = 'in_flight';
@@ -0,0 +1,22 @@
+-- the times stored so far are UTC ones, the remaining columns follow the bookings
+SET TIME ZONE 'UTC';
+
+ALTER TABLE booking_series ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+ALTER TABLE waitlist_entries ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+ALTER TABLE booking_events ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+ALTER TABLE calendar_tokens
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+ALTER TABLE booking_documents ALTER COLUMN uploaded_at TYPE TIMESTAMPTZ;
+ALTER TABLE rate_plans
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+ALTER TABLE cancellation_policies
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+ALTER TABLE payments
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+ALTER TABLE payment_transactions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
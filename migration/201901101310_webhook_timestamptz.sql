@@ -0,0 +1,8 @@
+-- the times stored so far are UTC ones, the due deliveries are compared with now()
+SET TIME ZONE 'UTC';
+
+ALTER TABLE webhook_endpoints ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN delivered_at TYPE TIMESTAMPTZ;
@@ -0,0 +1,298 @@
+package notifier
+
+import (
+	"bookings/dbmodels"
+	"bookings/events"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qor/i18n"
+	"github.com/qor/i18n/backends/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
+// Notification kinds, they select the translations of the subject and the body
+const (
+	KindCreated   = "created"
+	KindApproved  = "approved"
+	KindRejected  = "rejected"
+	KindCancelled = "cancelled"
+	KindOffered   = "offered"
+	KindMoved     = "moved"
+	KindEscalated = "escalated"
+	KindMessage   = "message"
+)
+
+// stateKinds maps the states a booking moves to with the notification sent about it
+var stateKinds = map[string]string{
+	"booked":    KindApproved,
+	"rejected":  KindRejected,
+	"cancelled": KindCancelled,
+}
+
+const (
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+)
+
+// Config defines the notifier configuration options
+type Config struct {
+	SMTPAddr     string `envconfig:"smtp_addr" default:"localhost:25"`
+	SMTPUser     string `envconfig:"smtp_user"`
+	SMTPPassword string `vaultconfig:"secret/smtp/bookings"`
+	From         string `envconfig:"notification_from" default:"bookings@localhost"`
+	Lang         string `envconfig:"notification_lang" default:"en-GB"`
+	Workers      int    `envconfig:"notification_workers" default:"2"`
+	MaxAttempts  int    `envconfig:"notification_max_attempts" default:"5"`
+	QueueSize    int    `envconfig:"notification_queue_size" default:"1000"`
+}
+
+// Notifier sends the booking notification emails from an asynchronous queue, failed
+// deliveries are retried with exponential backoff
+type Notifier struct {
+	conf   Config
+	sender Sender
+	i18n   *i18n.I18n
+	queue  chan job
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type job struct {
+	msg     Message
+	attempt int
+}
+
+// templateData is available in the subject and body translations
+type templateData struct {
+	Booking   dbmodels.Booking
+	Room      string
+	State     string
+	StartTime string
+	EndTime   string
+	ExpiresAt string
+	Message   string
+}
+
+// New creates a notifier
+func New(conf Config, sender Sender) *Notifier {
+	if conf.Workers <= 0 {
+		conf.Workers = 1
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = 1
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = 100
+	}
+	return &Notifier{
+		conf:   conf,
+		sender: sender,
+		i18n:   i18n.New(yaml.New("translations")),
+		queue:  make(chan job, conf.QueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start starts the workers and subscribes to the booking events
+func (n *Notifier) Start() {
+	for i := 0; i < n.conf.Workers; i++ {
+		n.wg.Add(1)
+		go n.work()
+	}
+	events.SubscribeLocal(n.handle)
+}
+
+// Stop stops the workers, the queued messages are dropped
+func (n *Notifier) Stop() {
+	close(n.done)
+	n.wg.Wait()
+}
+
+// Enqueue queues a message, it returns false when the queue is full or the notifier is stopped
+func (n *Notifier) Enqueue(msg Message) bool {
+	return n.enqueue(job{msg: msg})
+}
+
+func (n *Notifier) enqueue(j job) bool {
+	select {
+	case <-n.done:
+		return false
+	default:
+	}
+	select {
+	case n.queue <- j:
+		return true
+	default:
+		log.Errorf("Notification queue is full, dropping %q to %v", j.msg.Subject, j.msg.To)
+		return false
+	}
+}
+
+func (n *Notifier) work() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.done:
+			return
+		case j := <-n.queue:
+			n.send(j)
+		}
+	}
+}
+
+func (n *Notifier) send(j job) {
+	err := n.sender.Send(j.msg)
+	if err == nil {
+		return
+	}
+	j.attempt++
+	if j.attempt >= n.conf.MaxAttempts {
+		log.Errorf("Giving up sending %q to %v after %d attempts: %s", j.msg.Subject, j.msg.To, j.attempt, err)
+		return
+	}
+	delay := retryBaseDelay << uint(j.attempt-1)
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	log.Warnf("Sending %q to %v failed, retrying in %s: %s", j.msg.Subject, j.msg.To, delay, err)
+	time.AfterFunc(delay, func() { n.enqueue(j) })
+}
+
+// handle turns the booking events into notifications
+func (n *Notifier) handle(ev events.BookingEvent) {
+	var kind string
+	switch ev.Type {
+	case events.BookingCreated:
+		// holds are notified once they are confirmed
+		if ev.State != "draft" {
+			kind = KindCreated
+		}
+	case events.BookingUpdated:
+		switch {
+		case ev.State == ev.PreviousState:
+		case ev.PreviousState == "draft" && ev.State == "pending":
+			kind = KindCreated
+		default:
+			kind = stateKinds[ev.State]
+		}
+	case events.BookingMoved:
+		kind = KindMoved
+	case events.BookingEscalated:
+		go n.escalate(ev)
+		return
+	case events.BookingMessage:
+		// the provider gets the messages of the customer from the webhooks and the stream
+		kind = KindMessage
+	}
+	if kind == "" {
+		return
+	}
+
+	var b dbmodels.Booking
+	if err := json.Unmarshal(ev.Booking, &b); err != nil {
+		log.Errorf("Failed to decode booking of event %s: %s", ev.Type, err)
+		return
+	}
+	if kind == KindMoved {
+		go n.moved(b)
+		return
+	}
+	text := ""
+	if kind == KindMessage {
+		if b.Message == nil || b.Message.AuthorRole != dbmodels.MessageFromProvider {
+			return
+		}
+		text = b.Message.Body
+	}
+	msg, ok := n.Compose(kind, &b, text)
+	if ok {
+		n.Enqueue(msg)
+	}
+}
+
+// moved notifies the move of the booking with the name of its new room
+func (n *Notifier) moved(b dbmodels.Booking) {
+	bookings := []dbmodels.Booking{b}
+	if err := dbmodels.EmbedResources(bookings, []string{dbmodels.EmbedRoom}); err != nil {
+		log.Errorf("Failed to embed the room of moved booking %s: %s", b.ID, err)
+	}
+	if msg, ok := n.Compose(KindMoved, &bookings[0], ""); ok {
+		n.Enqueue(msg)
+	}
+}
+
+// escalate notifies the escalation email of the approval SLA of the booking
+func (n *Notifier) escalate(ev events.BookingEvent) {
+	var b dbmodels.Booking
+	if err := json.Unmarshal(ev.Booking, &b); err != nil {
+		log.Errorf("Failed to decode booking of event %s: %s", ev.Type, err)
+		return
+	}
+	to, err := dbmodels.GetApprovalEscalationEmail(b.RoomID)
+	if err != nil {
+		log.Errorf("Failed to get the escalation email of booking %s: %s", b.ID, err)
+		return
+	}
+	if to != "" {
+		n.Enqueue(n.ComposeTo(to, KindEscalated, &b, ""))
+	}
+}
+
+// Compose creates the localized message of the given kind about the booking, it returns
+// false when the booking has no email address to notify
+func (n *Notifier) Compose(kind string, b *dbmodels.Booking, text string) (Message, bool) {
+	if b.BookingRequestEmail == nil || *b.BookingRequestEmail == "" {
+		return Message{}, false
+	}
+	return n.ComposeTo(*b.BookingRequestEmail, kind, b, text), true
+}
+
+// ComposeTo creates the localized message of the given kind about the booking to the address.
+// The messages are always sent from the configured address, the address given with the booking
+// request only receives the replies.
+func (n *Notifier) ComposeTo(to, kind string, b *dbmodels.Booking, text string) Message {
+	replyTo := ""
+	if b.BookingRequestFromEmail != nil {
+		replyTo = *b.BookingRequestFromEmail
+	}
+	data := templateData{
+		Booking:   *b,
+		State:     n.stateLabel(b.State),
+		StartTime: b.StartTime.UTC().Format("2006-01-02 15:04 MST"),
+		EndTime:   b.EndTime.UTC().Format("2006-01-02 15:04 MST"),
+		Message:   text,
+	}
+	if b.Room != nil {
+		data.Room = b.Room.Name
+	}
+	if b.ExpiresAt != nil {
+		data.ExpiresAt = b.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")
+	}
+	return Message{
+		From:    n.conf.From,
+		ReplyTo: replyTo,
+		To:      []string{to},
+		Subject: n.translate("notifications."+kind+".subject", data),
+		Body:    n.translate("notifications."+kind+".body", data),
+	}
+}
+
+// stateLabel returns the display label of the state, the labels are kept apart from the state
+// keys which the API returns as they are
+func (n *Notifier) stateLabel(state string) string {
+	key := "states." + state
+	if label := n.translate(key, nil); label != key {
+		return label
+	}
+	return state
+}
+
+func (n *Notifier) translate(key string, data interface{}) string {
+	if data == nil {
+		return fmt.Sprint(n.i18n.T(n.conf.Lang, key))
+	}
+	return fmt.Sprint(n.i18n.T(n.conf.Lang, key, data))
+}
@@ -0,0 +1,120 @@
+package notifier
+
+import (
+	"bookings/dbmodels"
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func testBooking(from string) *dbmodels.Booking {
+	to := "guest@example.com"
+	b := &dbmodels.Booking{
+		ID:                  uuid.NewV4(),
+		State:               "booked",
+		StartTime:           time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC),
+		EndTime:             time.Date(2019, 1, 12, 10, 0, 0, 0, time.UTC),
+		BookingRequestEmail: &to,
+	}
+	if from != "" {
+		b.BookingRequestFromEmail = &from
+	}
+	return b
+}
+
+func TestComposeSendsFromConfiguredAddress(t *testing.T) {
+	n := New(Config{From: "bookings@example.com"}, nil)
+
+	msg, ok := n.Compose(KindApproved, testBooking("agent@example.org"), "")
+	if !ok {
+		t.Fatal("expected a message")
+	}
+	if msg.From != "bookings@example.com" {
+		t.Errorf("From = %q, want the configured address", msg.From)
+	}
+	if msg.ReplyTo != "agent@example.org" {
+		t.Errorf("ReplyTo = %q, want the address of the booking request", msg.ReplyTo)
+	}
+	if len(msg.To) != 1 || msg.To[0] != "guest@example.com" {
+		t.Errorf("To = %v", msg.To)
+	}
+
+	msg, _ = n.Compose(KindApproved, testBooking(""), "")
+	if msg.From != "bookings@example.com" || msg.ReplyTo != "" {
+		t.Errorf("From = %q, ReplyTo = %q without a booking request address", msg.From, msg.ReplyTo)
+	}
+}
+
+func TestComposeWithoutRecipient(t *testing.T) {
+	n := New(Config{From: "bookings@example.com"}, nil)
+	b := testBooking("")
+	b.BookingRequestEmail = nil
+	if _, ok := n.Compose(KindApproved, b, ""); ok {
+		t.Error("expected no message without a booking request email")
+	}
+}
+
+func TestMessageBytesRejectsHeaderInjection(t *testing.T) {
+	for _, msg := range []Message{
+		{From: "bookings@example.com\r\nBcc: x@example.com", To: []string{"guest@example.com"}},
+		{From: "bookings@example.com", ReplyTo: "a@example.org\nBcc: x@example.com", To: []string{"guest@example.com"}},
+		{From: "bookings@example.com", To: []string{"guest@example.com\r\nBcc: x@example.com"}},
+	} {
+		if _, err := msg.Bytes(); err == nil {
+			t.Errorf("expected an error for %+v", msg)
+		}
+	}
+}
+
+func TestNotifierDeliversToSMTPSink(t *testing.T) {
+	sink, err := NewSMTPSink("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	conf := Config{SMTPAddr: sink.Addr(), From: "bookings@example.com", Workers: 1, MaxAttempts: 1}
+	n := New(conf, NewSMTPSender(conf))
+	n.wg.Add(1)
+	go n.work()
+	defer n.Stop()
+
+	msg, _ := n.Compose(KindApproved, testBooking("agent@example.org"), "")
+	msg.Subject = "Booking request approved"
+	msg.Body = "Your booking request has been approved."
+	if !n.Enqueue(msg) {
+		t.Fatal("message not queued")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.Messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages := sink.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From != "bookings@example.com" {
+		t.Errorf("envelope sender = %q, want the configured address", got.From)
+	}
+	if len(got.To) != 1 || got.To[0] != "guest@example.com" {
+		t.Errorf("envelope recipients = %v", got.To)
+	}
+	data := string(got.Data)
+	for _, header := range []string{
+		"From: bookings@example.com\n",
+		"Reply-To: agent@example.org\n",
+		"To: guest@example.com\n",
+		"Subject: Booking request approved\n",
+	} {
+		if !strings.Contains(data, header) {
+			t.Errorf("message lacks %q:\n%s", header, data)
+		}
+	}
+	if !strings.Contains(data, "Your booking request has been approved.") {
+		t.Errorf("message lacks the body:\n%s", data)
+	}
+}
@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// SinkMessage is a message received by the SMTP sink
+type SinkMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// SMTPSink is a fake SMTP server recording the received messages instead of delivering
+// them, it stands in for the mail server in tests and local setups
+type SMTPSink struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []SinkMessage
+}
+
+// NewSMTPSink starts a sink listening on addr, e.g. "127.0.0.1:0"
+func NewSMTPSink(addr string) (*SMTPSink, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTPSink{ln: ln}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the sink listens on
+func (s *SMTPSink) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Messages returns the messages received so far
+func (s *SMTPSink) Messages() []SinkMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SinkMessage{}, s.messages...)
+}
+
+// Close stops the sink
+func (s *SMTPSink) Close() error {
+	return s.ln.Close()
+}
+
+func (s *SMTPSink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *SMTPSink) session(conn net.Conn) {
+	tc := textproto.NewConn(conn)
+	defer tc.Close()
+
+	var msg SinkMessage
+	tc.PrintfLine("220 bookings-sink ESMTP")
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		arg := strings.TrimSpace(strings.TrimPrefix(line, strings.SplitN(line, " ", 2)[0]))
+		switch cmd {
+		case "EHLO":
+			tc.PrintfLine("250-bookings-sink")
+			tc.PrintfLine("250-8BITMIME")
+			tc.PrintfLine("250 AUTH PLAIN")
+		case "HELO":
+			tc.PrintfLine("250 bookings-sink")
+		case "AUTH":
+			tc.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			msg = SinkMessage{From: sinkAddress(arg)}
+			tc.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, sinkAddress(arg))
+			tc.PrintfLine("250 OK")
+		case "DATA":
+			tc.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = SinkMessage{}
+			tc.PrintfLine("250 OK")
+		case "RSET":
+			msg = SinkMessage{}
+			tc.PrintfLine("250 OK")
+		case "NOOP":
+			tc.PrintfLine("250 OK")
+		case "QUIT":
+			tc.PrintfLine("221 Bye")
+			return
+		default:
+			tc.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// sinkAddress extracts the address of "FROM:<a@b>" or "TO:<a@b>"
+func sinkAddress(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return arg
+	}
+	return arg[start+1 : end]
+}
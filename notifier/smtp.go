@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is an email
+type Message struct {
+	From    string
+	ReplyTo string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers the messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender sends the messages through an SMTP server
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates a sender for the configured SMTP server
+func NewSMTPSender(conf Config) *SMTPSender {
+	s := &SMTPSender{addr: conf.SMTPAddr}
+	if conf.SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(conf.SMTPAddr)
+		s.auth = smtp.PlainAuth("", conf.SMTPUser, conf.SMTPPassword, host)
+	}
+	return s
+}
+
+// Send sends the message
+func (s *SMTPSender) Send(msg Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, msg.From, msg.To, data)
+}
+
+// Bytes formats the message as a plain text RFC 5322 email
+func (msg Message) Bytes() ([]byte, error) {
+	for _, v := range append([]string{msg.From, msg.ReplyTo}, msg.To...) {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("invalid address %q", v)
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", msg.From)
+	if msg.ReplyTo != "" {
+		fmt.Fprintf(&buf, "Reply-To: %s\r\n", msg.ReplyTo)
+	}
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,172 @@
+package payments
+
+import (
+	"bookings/dbmodels"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// FakeName is the name of the fake payment provider
+const FakeName = "fake"
+
+// fakeAuthorization is an authorization held by the fake provider
+type fakeAuthorization struct {
+	amount   int64
+	captured int64
+	refunded int64
+	voided   bool
+}
+
+// Fake is a local payment provider keeping the authorizations in memory, it declines the
+// authorizations above DeclineAbove and, with an AsyncDelay, answers pending and posts the
+// result to the callback URL after the delay. An operation sent again with the same
+// idempotency key gets the result of the first one.
+type Fake struct {
+	conf    Config
+	client  *http.Client
+	mu      sync.Mutex
+	auths   map[string]*fakeAuthorization
+	results map[string]Result
+}
+
+// NewFake creates a fake payment provider
+func NewFake(conf Config) *Fake {
+	return &Fake{
+		conf:    conf,
+		client:  &http.Client{Timeout: conf.Timeout},
+		auths:   map[string]*fakeAuthorization{},
+		results: map[string]Result{},
+	}
+}
+
+// Name returns the name of the provider
+func (f *Fake) Name() string {
+	return FakeName
+}
+
+// Authorize holds the amount
+func (f *Fake) Authorize(ctx context.Context, req Request) (Result, error) {
+	return f.complete(req.IdempotencyKey, func() (string, error) {
+		if f.conf.FakeDeclineAbove > 0 && req.Amount > f.conf.FakeDeclineAbove {
+			return "", fmt.Errorf("declined: amount above %d", f.conf.FakeDeclineAbove)
+		}
+		ref := "fake_auth_" + uuid.NewV4().String()
+		f.auths[ref] = &fakeAuthorization{amount: req.Amount}
+		return ref, nil
+	})
+}
+
+// Capture collects a part of the authorized amount not captured yet
+func (f *Fake) Capture(ctx context.Context, req Request) (Result, error) {
+	return f.complete(req.IdempotencyKey, func() (string, error) {
+		auth, err := f.authorization(req.Reference)
+		if err != nil {
+			return "", err
+		}
+		if auth.captured+req.Amount > auth.amount {
+			return "", fmt.Errorf("capture of %d exceeds the authorized amount", req.Amount)
+		}
+		auth.captured += req.Amount
+		return "fake_capture_" + uuid.NewV4().String(), nil
+	})
+}
+
+// Refund pays back a part of the captured amount
+func (f *Fake) Refund(ctx context.Context, req Request) (Result, error) {
+	return f.complete(req.IdempotencyKey, func() (string, error) {
+		auth, err := f.authorization(req.Reference)
+		if err != nil {
+			return "", err
+		}
+		if auth.refunded+req.Amount > auth.captured {
+			return "", fmt.Errorf("refund of %d exceeds the captured amount", req.Amount)
+		}
+		auth.refunded += req.Amount
+		return "fake_refund_" + uuid.NewV4().String(), nil
+	})
+}
+
+// Void releases an authorization without capture
+func (f *Fake) Void(ctx context.Context, req Request) (Result, error) {
+	return f.complete(req.IdempotencyKey, func() (string, error) {
+		auth, err := f.authorization(req.Reference)
+		if err != nil {
+			return "", err
+		}
+		if auth.captured > 0 {
+			return "", fmt.Errorf("a captured authorization can't be voided")
+		}
+		auth.voided = true
+		return "fake_void_" + uuid.NewV4().String(), nil
+	})
+}
+
+func (f *Fake) authorization(ref string) (*fakeAuthorization, error) {
+	auth, ok := f.auths[ref]
+	if !ok || auth.voided {
+		return nil, fmt.Errorf("unknown authorization %q", ref)
+	}
+	return auth, nil
+}
+
+// complete runs the operation once per idempotency key and returns its result, or a pending
+// result when the result is posted to the callback URL later
+func (f *Fake) complete(key string, op func() (string, error)) (Result, error) {
+	async := f.conf.FakeAsyncDelay > 0 && f.conf.FakeCallbackURL != ""
+	f.mu.Lock()
+	res, done := f.results[key]
+	if !done {
+		ref, err := op()
+		res = Result{Status: dbmodels.TransactionSucceeded, Reference: ref}
+		if err != nil {
+			res = Result{Status: dbmodels.TransactionFailed, Message: err.Error()}
+		}
+		if async && res.Reference == "" {
+			res.Reference = "fake_failure_" + uuid.NewV4().String()
+		}
+		if key != "" {
+			f.results[key] = res
+		}
+	}
+	f.mu.Unlock()
+
+	if !async {
+		return res, nil
+	}
+	if !done {
+		time.AfterFunc(f.conf.FakeAsyncDelay, func() { f.callback(res) })
+	}
+	return Result{Status: dbmodels.TransactionPending, Reference: res.Reference}, nil
+}
+
+func (f *Fake) callback(res Result) {
+	body, err := json.Marshal(Callback{Reference: res.Reference, Status: res.Status, Message: res.Message})
+	if err != nil {
+		log.Errorf("Failed to encode fake payment callback: %s", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, f.conf.FakeCallbackURL, bytes.NewReader(body))
+	if err != nil {
+		log.Errorf("Failed to create fake payment callback: %s", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(f.conf.CallbackSecret, body))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		log.Errorf("Failed to send fake payment callback %s: %s", res.Reference, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Errorf("Fake payment callback %s answered %d", res.Reference, resp.StatusCode)
+	}
+}
@@ -0,0 +1,370 @@
+package payments
+
+import (
+	"bookings/dbmodels"
+	"bookings/events"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the callback body
+const SignatureHeader = "X-Payment-Signature"
+
+// consumerName names the position of the manager in the booking events log
+const consumerName = "payments"
+
+// Config defines the payments configuration options
+type Config struct {
+	Provider       string        `envconfig:"payment_provider" default:"fake"`
+	CallbackSecret string        `vaultconfig:"secret/payments/bookings"`
+	Timeout        time.Duration `envconfig:"payment_timeout" default:"30s"`
+	PollInterval   time.Duration `envconfig:"payment_poll_interval" default:"2s"`
+	BatchSize      int           `envconfig:"payment_batch_size" default:"50"`
+	// the options of the fake provider
+	FakeDeclineAbove int64         `envconfig:"fake_payment_decline_above"`
+	FakeAsyncDelay   time.Duration `envconfig:"fake_payment_async_delay"`
+	FakeCallbackURL  string        `envconfig:"fake_payment_callback_url"`
+}
+
+// Callback is the asynchronous result of an operation posted by the payment provider
+type Callback struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+}
+
+// Manager runs the payment operations following the booking events. The events are read from
+// the booking events log, from the position recorded after the last one handled, so that the
+// events committed while no instance was running are handled as well. The operations are
+// recorded in flight before they are sent to the provider, the ones interrupted by a crash are
+// sent again with the same idempotency key before the following events are handled.
+type Manager struct {
+	// id identifies the manager as the holder of the lease of its position
+	id       uuid.UUID
+	conf     Config
+	provider PaymentProvider
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a manager for the configured provider
+func New(conf Config) (*Manager, error) {
+	var provider PaymentProvider
+	switch conf.Provider {
+	case FakeName:
+		provider = NewFake(conf)
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", conf.Provider)
+	}
+	if conf.PollInterval <= 0 {
+		conf.PollInterval = 2 * time.Second
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 50
+	}
+	if conf.CallbackSecret == "" {
+		log.Warn("No payment callback secret is configured, the payment callbacks are rejected")
+	}
+	return &Manager{
+		id:       uuid.NewV4(),
+		conf:     conf,
+		provider: provider,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start starts following the booking events log, the events committed by this instance
+// wake the manager up before the next poll
+func (m *Manager) Start() {
+	events.SubscribeLocal(func(events.BookingEvent) {
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	})
+	go m.follow()
+}
+
+// Stop stops following the booking events log
+func (m *Manager) Stop() {
+	close(m.done)
+}
+
+func (m *Manager) follow() {
+	ticker := time.NewTicker(m.conf.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+		if err := m.consume(); err != nil {
+			log.Errorf("Failed to handle the booking events of the payments: %s", err)
+		}
+	}
+}
+
+// consume handles the events of the log following the position of the manager, it does
+// nothing while another instance holds the lease of the position. It stops at an event whose
+// operation is left in flight, the event is handled again once the operation is answered.
+func (m *Manager) consume() error {
+	// the lease covers the operations of a batch, it is renewed before each one
+	lease := m.conf.Timeout*time.Duration(m.conf.BatchSize) + time.Minute
+	cursor, ok, err := dbmodels.ClaimEventConsumer(consumerName, m.id, lease)
+	if err != nil || !ok {
+		return err
+	}
+	defer func() {
+		if err := dbmodels.ReleaseEventConsumer(consumerName, m.id); err != nil {
+			log.Errorf("Failed to release the payments events position: %s", err)
+		}
+	}()
+	if err = m.reconcile(); err != nil {
+		return err
+	}
+	for {
+		if err = dbmodels.RenewEventConsumer(consumerName, m.id, lease); err != nil {
+			return err
+		}
+		evs, err := dbmodels.GetBookingEventsAfter(cursor, m.conf.BatchSize)
+		if err != nil {
+			return err
+		}
+		for i := range evs {
+			if err = m.handle(evs[i]); err != nil {
+				return err
+			}
+			cursor = dbmodels.CursorOf(&evs[i])
+			if err = dbmodels.AdvanceEventConsumer(consumerName, m.id, cursor); err != nil {
+				return err
+			}
+		}
+		if len(evs) < m.conf.BatchSize {
+			return nil
+		}
+	}
+}
+
+// Sign returns the signature of a callback body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// reconcile sends the operations left in flight again, the provider runs an operation sent
+// twice with the same idempotency key once
+func (m *Manager) reconcile() error {
+	ts, err := dbmodels.GetInFlightPaymentTransactions(m.conf.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range ts {
+		p, _, err := dbmodels.GetPayment(ts[i].PaymentID)
+		if err != nil {
+			return err
+		}
+		log.Infof("Sending %s of payment %s again", ts[i].Operation, p.ID)
+		if err = m.send(p, &ts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle runs the payment operation following the event, it fails when the operation is left
+// in flight
+func (m *Manager) handle(ev events.BookingEvent) error {
+	if ev.Type != events.BookingDeleted && ev.State == ev.PreviousState {
+		return nil
+	}
+	var b dbmodels.Booking
+	if err := json.Unmarshal(ev.Booking, &b); err != nil {
+		log.Errorf("Failed to decode booking of event %s: %s", ev.Type, err)
+		return nil
+	}
+	switch {
+	case ev.Type == events.BookingDeleted, ev.State == "rejected":
+		return m.release(&b)
+	case ev.State == "cancelled":
+		return m.settle(&b)
+	case ev.State == "completed":
+		return m.capture(&b)
+	case ev.State == "pending" || ev.State == "pending_resp" || ev.State == "booked":
+		// a new booking request or a confirmed hold
+		if ev.Type == events.BookingCreated || ev.PreviousState == "draft" {
+			return m.authorize(&b)
+		}
+	}
+	return nil
+}
+
+// authorize authorizes the price of the booking. An event handled again finds the payment
+// created the first time, it is authorized unless an authorization was already started.
+func (m *Manager) authorize(b *dbmodels.Booking) error {
+	if b.TotalPrice == nil || *b.TotalPrice == 0 || b.Currency == nil {
+		return nil
+	}
+	p, err := dbmodels.CreatePayment(b.ID, m.provider.Name(), *b.Currency, *b.TotalPrice)
+	if err != nil {
+		log.Errorf("Failed to create the payment of booking %s: %s", b.ID, err)
+		return nil
+	}
+	if p == nil {
+		if p = m.payment(b); p == nil || p.Status != dbmodels.PaymentAuthorizing || len(p.Transactions) > 0 {
+			return nil
+		}
+	}
+	return m.run(p, dbmodels.OpAuthorize, p.Amount)
+}
+
+// capture collects the authorized amount of a completed booking
+func (m *Manager) capture(b *dbmodels.Booking) error {
+	p := m.payment(b)
+	if p != nil && p.Status == dbmodels.PaymentAuthorized {
+		return m.run(p, dbmodels.OpCapture, p.Amount-p.Captured)
+	}
+	return nil
+}
+
+// settle keeps the cancellation fee of a cancelled booking, the rest is released or refunded
+func (m *Manager) settle(b *dbmodels.Booking) error {
+	p := m.payment(b)
+	if p == nil {
+		return nil
+	}
+	var fee int64
+	if b.CancellationFee != nil {
+		fee = *b.CancellationFee
+	}
+	if fee > p.Amount {
+		fee = p.Amount
+	}
+	switch {
+	case p.Status == dbmodels.PaymentCaptured && p.Captured-p.Refunded > fee:
+		return m.run(p, dbmodels.OpRefund, p.Captured-p.Refunded-fee)
+	case p.Status == dbmodels.PaymentAuthorized && fee > 0:
+		return m.run(p, dbmodels.OpCapture, fee)
+	case p.Status == dbmodels.PaymentAuthorized:
+		return m.run(p, dbmodels.OpVoid, 0)
+	}
+	return nil
+}
+
+// release voids or refunds the whole payment of a rejected or deleted booking
+func (m *Manager) release(b *dbmodels.Booking) error {
+	p := m.payment(b)
+	if p == nil {
+		return nil
+	}
+	switch {
+	case p.Status == dbmodels.PaymentAuthorized:
+		return m.run(p, dbmodels.OpVoid, 0)
+	case p.Status == dbmodels.PaymentCaptured && p.Captured > p.Refunded:
+		return m.run(p, dbmodels.OpRefund, p.Captured-p.Refunded)
+	}
+	return nil
+}
+
+func (m *Manager) payment(b *dbmodels.Booking) *dbmodels.Payment {
+	p, state, err := dbmodels.GetBookingPayment(b.ID)
+	if err != nil {
+		if state != http.StatusNotFound {
+			log.Errorf("Failed to get the payment of booking %s: %s", b.ID, err)
+		}
+		return nil
+	}
+	return p
+}
+
+// run records the transaction in flight and sends it to the provider
+func (m *Manager) run(p *dbmodels.Payment, operation string, amount int64) error {
+	t, err := dbmodels.StartPaymentTransaction(p.ID, operation, amount)
+	if err != nil {
+		log.Errorf("Failed to record %s of payment %s: %s", operation, p.ID, err)
+		return nil
+	}
+	return m.send(p, t)
+}
+
+// send sends the transaction to the provider and records its result. The transaction stays in
+// flight when the provider didn't answer or its result wasn't recorded.
+func (m *Manager) send(p *dbmodels.Payment, t *dbmodels.PaymentTransaction) error {
+	operation, amount := t.Operation, t.Amount
+	req := Request{
+		PaymentID:      p.ID,
+		BookingID:      p.BookingID,
+		IdempotencyKey: t.ID.String(),
+		Amount:         amount,
+		Currency:       p.Currency,
+	}
+	if p.Reference != nil {
+		req.Reference = *p.Reference
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.conf.Timeout)
+	defer cancel()
+
+	var res Result
+	var err error
+	switch operation {
+	case dbmodels.OpAuthorize:
+		res, err = m.provider.Authorize(ctx, req)
+	case dbmodels.OpCapture:
+		res, err = m.provider.Capture(ctx, req)
+	case dbmodels.OpRefund:
+		res, err = m.provider.Refund(ctx, req)
+	case dbmodels.OpVoid:
+		res, err = m.provider.Void(ctx, req)
+	}
+	if err != nil {
+		return fmt.Errorf("%s of payment %s is left in flight: %s", operation, p.ID, err)
+	}
+	if res.Status == dbmodels.TransactionFailed {
+		log.Warnf("Payment %s %s of %d failed: %s", p.ID, operation, amount, res.Message)
+	}
+	if _, err = dbmodels.CompletePaymentTransaction(t.ID, res.Status, res.Reference, res.Message); err != nil {
+		return fmt.Errorf("%s of payment %s is left in flight, its result wasn't recorded: %s", operation, p.ID, err)
+	}
+	return nil
+}
+
+// HandleCallback verifies the signature of a callback of the provider and records its result
+func (m *Manager) HandleCallback(provider string, body []byte, signature string) (int, error) {
+	if provider != m.provider.Name() {
+		return http.StatusNotFound, fmt.Errorf("unknown payment provider %q", provider)
+	}
+	// a signature keyed by an empty secret can be forged by anyone
+	if m.conf.CallbackSecret == "" {
+		return http.StatusServiceUnavailable, fmt.Errorf("payment callbacks are disabled, no callback secret is configured")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(m.conf.CallbackSecret, body))) {
+		return http.StatusUnauthorized, fmt.Errorf("invalid signature")
+	}
+	var cb Callback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if cb.Status != dbmodels.TransactionSucceeded && cb.Status != dbmodels.TransactionFailed {
+		return http.StatusBadRequest, fmt.Errorf("status must be %q or %q", dbmodels.TransactionSucceeded, dbmodels.TransactionFailed)
+	}
+	t, state, err := dbmodels.GetPaymentTransactionByReference(cb.Reference)
+	if err != nil {
+		return state, err
+	}
+	if _, err = dbmodels.CompletePaymentTransaction(t.ID, cb.Status, cb.Reference, cb.Message); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusNoContent, nil
+}
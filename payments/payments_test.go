@@ -0,0 +1,88 @@
+package payments
+
+import (
+	"bookings/dbmodels"
+	"context"
+	"net/http"
+	"testing"
+)
+
+const callbackBody = `{"reference":"ref-1","status":"succeeded"}`
+
+func TestSign(t *testing.T) {
+	// echo -n '{"reference":"ref-1","status":"succeeded"}' | openssl dgst -sha256 -hmac cbsecret
+	want := "86eddc569879181131f20d6801ef8fe4a99c122d6dd6cdfa49ee21512d001ee0"
+	if got := Sign("cbsecret", []byte(callbackBody)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("other", []byte(callbackBody)) == want {
+		t.Error("the signature doesn't depend on the secret")
+	}
+}
+
+func TestHandleCallbackRejectsUnverifiedCallbacks(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		provider  string
+		signature string
+		state     int
+	}{
+		{"unknown provider", "cbsecret", "other", Sign("cbsecret", []byte(callbackBody)), http.StatusNotFound},
+		{"no secret configured", "", FakeName, Sign("", []byte(callbackBody)), http.StatusServiceUnavailable},
+		{"missing signature", "cbsecret", FakeName, "", http.StatusUnauthorized},
+		{"signature of another secret", "cbsecret", FakeName, Sign("other", []byte(callbackBody)), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		m, err := New(Config{Provider: FakeName, CallbackSecret: tt.secret})
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := m.HandleCallback(tt.provider, []byte(callbackBody), tt.signature)
+		if err == nil || state != tt.state {
+			t.Errorf("%s: state %d, error %v, want %d", tt.name, state, err, tt.state)
+		}
+	}
+}
+
+func TestHandleCallbackValidatesTheBody(t *testing.T) {
+	m, err := New(Config{Provider: FakeName, CallbackSecret: "cbsecret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{`not json`, `{"reference":"ref-1","status":"pending"}`} {
+		state, err := m.HandleCallback(FakeName, []byte(body), Sign("cbsecret", []byte(body)))
+		if err == nil || state != http.StatusBadRequest {
+			t.Errorf("%s: state %d, error %v, want 400", body, state, err)
+		}
+	}
+}
+
+func TestFakeRunsAnOperationOncePerIdempotencyKey(t *testing.T) {
+	f := NewFake(Config{})
+	ctx := context.Background()
+	auth, err := f.Authorize(ctx, Request{IdempotencyKey: "auth-1", Amount: 1000, Currency: "EUR"})
+	if err != nil || auth.Status != dbmodels.TransactionSucceeded {
+		t.Fatalf("Authorize = %+v, %v", auth, err)
+	}
+	again, _ := f.Authorize(ctx, Request{IdempotencyKey: "auth-1", Amount: 1000, Currency: "EUR"})
+	if again != auth {
+		t.Errorf("Authorize sent again = %+v, want %+v", again, auth)
+	}
+
+	capture := Request{Reference: auth.Reference, IdempotencyKey: "capture-1", Amount: 600, Currency: "EUR"}
+	first, _ := f.Capture(ctx, capture)
+	// a capture interrupted by a crash is sent again with the same key
+	second, _ := f.Capture(ctx, capture)
+	if first.Status != dbmodels.TransactionSucceeded || second != first {
+		t.Errorf("Capture = %+v then %+v, want the same succeeded result", first, second)
+	}
+	if captured := f.auths[auth.Reference].captured; captured != 600 {
+		t.Errorf("captured %d, want 600", captured)
+	}
+
+	capture.IdempotencyKey = "capture-2"
+	if res, _ := f.Capture(ctx, capture); res.Status != dbmodels.TransactionFailed {
+		t.Errorf("a second capture over the authorized amount = %+v, want failed", res)
+	}
+}
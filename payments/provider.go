@@ -0,0 +1,39 @@
+// Package payments authorizes the payment of the priced bookings, captures it once they are
+// completed, and voids or refunds it on cancellation according to the cancellation policy
+package payments
+
+import (
+	"context"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Request is an operation on a payment, the amount is in the minor unit of the currency
+type Request struct {
+	PaymentID uuid.UUID
+	BookingID uuid.UUID
+	// Reference is the reference of the authorization, it is empty for Authorize
+	Reference string
+	// IdempotencyKey identifies the operation, an operation sent again with the same key is
+	// answered with the result of the first one
+	IdempotencyKey string
+	Amount         int64
+	Currency       string
+}
+
+// Result is the outcome of an operation, a pending one is completed later by a callback
+// carrying the same reference
+type Result struct {
+	Status    string
+	Reference string
+	Message   string
+}
+
+// PaymentProvider executes the operations of a payment service provider
+type PaymentProvider interface {
+	Name() string
+	Authorize(ctx context.Context, req Request) (Result, error)
+	Capture(ctx context.Context, req Request) (Result, error)
+	Refund(ctx context.Context, req Request) (Result, error)
+	Void(ctx context.Context, req Request) (Result, error)
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"bookings/dbmodels"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// enforceApprovalSLAs rejects or escalates the booking requests not answered within the
+// approval SLA of their hotel batch by batch
+func enforceApprovalSLAs(batch int) func() error {
+	return func() error {
+		for {
+			n, err := dbmodels.RejectOverdueApprovals(batch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Infof("Rejected %d booking requests past their approval SLA", n)
+			}
+			if n < batch {
+				break
+			}
+		}
+		for {
+			n, err := dbmodels.EscalateOverdueApprovals(batch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Infof("Escalated %d booking requests past their approval SLA", n)
+			}
+			if n < batch {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"bookings/dbmodels"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// expireHolds cancels the expired holds batch by batch until none is left
+func expireHolds(batch int) func() error {
+	return func() error {
+		for {
+			n, err := dbmodels.ExpireHolds(batch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Infof("Expired %d booking holds", n)
+			}
+			if n < batch {
+				return nil
+			}
+		}
+	}
+}
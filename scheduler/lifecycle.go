@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"bookings/dbmodels"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// completeBookings moves the booked bookings which ended to completed batch by batch
+func completeBookings(batch int) func() error {
+	return func() error {
+		for {
+			n, err := dbmodels.CompleteBookings(batch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Infof("Completed %d past bookings", n)
+			}
+			if n < batch {
+				return nil
+			}
+		}
+	}
+}
+
+// closeStalePending moves the pending bookings which started to the terminal state batch by batch
+func closeStalePending(state string, batch int) func() error {
+	return func() error {
+		for {
+			n, err := dbmodels.CloseStalePending(state, batch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Infof("Moved %d stale pending bookings to %s", n, state)
+			}
+			if n < batch {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package scheduler runs the periodic background jobs of the service
+package scheduler
+
+import (
+	"bookings/dbmodels"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config defines the scheduler configuration options
+type Config struct {
+	HoldSweepInterval time.Duration `envconfig:"hold_sweep_interval" default:"30s"`
+	HoldSweepBatch    int           `envconfig:"hold_sweep_batch" default:"100"`
+	// CompletionInterval is the period of the completion of the past bookings and of
+	// the closing of the pending ones which started, moved to StalePendingState
+	CompletionInterval time.Duration `envconfig:"completion_interval" default:"5m"`
+	CompletionBatch    int           `envconfig:"completion_batch" default:"500"`
+	StalePendingState  string        `envconfig:"stale_pending_state" default:"rejected"`
+	ApprovalInterval   time.Duration `envconfig:"approval_interval" default:"1m"`
+	ApprovalBatch      int           `envconfig:"approval_batch" default:"100"`
+}
+
+// Job is a task run periodically
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func() error
+}
+
+// Scheduler runs every job in its own goroutine until it is stopped
+type Scheduler struct {
+	jobs []Job
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a scheduler with the jobs of the service
+func New(conf Config) (*Scheduler, error) {
+	valid := false
+	for _, state := range dbmodels.StalePendingStates {
+		valid = valid || state == conf.StalePendingState
+	}
+	if !valid {
+		return nil, fmt.Errorf("stale pending state must be one of %v", dbmodels.StalePendingStates)
+	}
+	s := &Scheduler{done: make(chan struct{})}
+	s.Add(Job{Name: "expire holds", Interval: conf.HoldSweepInterval, Run: expireHolds(conf.HoldSweepBatch)})
+	s.Add(Job{Name: "complete bookings", Interval: conf.CompletionInterval, Run: completeBookings(conf.CompletionBatch)})
+	s.Add(Job{Name: "close stale pending bookings", Interval: conf.CompletionInterval,
+		Run: closeStalePending(conf.StalePendingState, conf.CompletionBatch)})
+	s.Add(Job{Name: "enforce approval SLAs", Interval: conf.ApprovalInterval, Run: enforceApprovalSLAs(conf.ApprovalBatch)})
+	return s, nil
+}
+
+// Add adds a job, it must be called before Start
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start starts running the jobs
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.run(job)
+	}
+}
+
+// Stop stops the jobs, it waits for the running ones to finish
+func (s *Scheduler) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(job Job) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			runJob(job)
+		}
+	}
+}
+
+func runJob(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Scheduled job %q failed: %v", job.Name, r)
+		}
+	}()
+	if err := job.Run(); err != nil {
+		log.Errorf("Scheduled job %q failed: %s", job.Name, err)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"bookings/dbmodels"
 	"bookings/handlers"
+	"bookings/importer"
 	"net/http"
 
 	"github.com/miketonks/swag/endpoint"
@@ -67,22 +68,34 @@ func bookingsCAPI() []*swagger.Endpoint {
 				Nullable:    true,
 				Description: "comma separated list of states {'draft', 'cancelled', 'approved', 'pending', 'pending_resp', 'rejected', 'completed'}",
 			},
+			"fields": {
+				Type:        "string",
+				Nullable:    true,
+				Description: "comma separated list of booking fields to return, e.g. 'id,state,start_time'",
+			},
+			"embed": {
+				Type:        "string",
+				Nullable:    true,
+				Description: "comma separated list of resources to include in the bookings {'room', 'hotel'}",
+			},
 		}),
 		endpoint.Response(http.StatusOK, []dbmodels.Booking{}, "Success"),
 		endpoint.Tags("Booking Requests CAPI"),
 	)
 	getBookingCustomer := endpoint.New("GET", "/booking_requests/{id}", "Get booking request",
-		endpoint.Handler(handlers.GetBooking),
-		endpoint.Description("Get booking request by its ID"),
+		endpoint.Handler(handlers.GetBookingCAPI),
+		endpoint.Description("Get booking request by its ID, /booking_requests/{id}.ics returns it as iCalendar"),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
-		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Query("fields", "string", "", "comma separated list of booking fields to return", false),
+		endpoint.Query("embed", "string", "", "comma separated list of resources to include {'room', 'hotel'}", false),
+		endpoint.Path("id", "string", "", "booking request id, optionally followed by .ics"),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "Success"),
 		endpoint.Tags("Booking Requests CAPI"),
 	)
 	postBookingCustomer := endpoint.New("POST", "/booking_requests", "Create a booking request",
 		endpoint.Handler(handlers.PostBooking),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
-		endpoint.Description("Create a booking request"),
+		endpoint.Description("Create a booking request, a series of bookings is created when an rrule is given"),
 		endpoint.Body(dbmodels.BookingPost{}, "booking request post body", true),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "SUCCESS"),
 		endpoint.Tags("Booking Requests CAPI"),
@@ -91,16 +104,182 @@ func bookingsCAPI() []*swagger.Endpoint {
 		endpoint.Handler(handlers.PatchBooking),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
 		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Query("scope", "string", "", "for bookings of a series: 'occurrence' (default), 'following' or 'series'", false),
 		endpoint.Description("Update a booking request"),
 		endpoint.Body(dbmodels.BookingPatch{}, "booking request patch body", true),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "UPDATED"),
 		endpoint.Tags("Booking Requests CAPI"),
 	)
+	getPaymentCustomer := endpoint.New("GET", "/booking_requests/{id}/payment", "Get the booking payment",
+		endpoint.Handler(handlers.GetBookingPaymentCAPI),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Description("Get the payment of a booking request with its authorize, capture, refund and void transactions"),
+		endpoint.Response(http.StatusOK, dbmodels.Payment{}, "Success"),
+		endpoint.Tags("Payments CAPI"),
+	)
+	getCancellationPreview := endpoint.New("GET", "/booking_requests/{id}/cancellation", "Preview a cancellation",
+		endpoint.Handler(handlers.GetCancellationPreview),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Description("Tell the policy and the fee applied when cancelling the booking request now, "+
+			"the fee is null for the bookings without price"),
+		endpoint.Response(http.StatusOK, dbmodels.CancellationPreview{}, "Success"),
+		endpoint.Tags("Booking Requests CAPI"),
+	)
+	postBookingHold := endpoint.New("POST", "/booking_holds", "Hold a slot",
+		endpoint.Handler(handlers.PostBookingHold),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Query("duration", "integer", "", "seconds the slot is held for, 600 by default and 3600 at most", false),
+		endpoint.Description("Create a draft booking request reserving its slot until it is confirmed or expires"),
+		endpoint.Body(dbmodels.BookingPost{}, "booking request post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "SUCCESS"),
+		endpoint.Tags("Booking Requests CAPI"),
+	)
+	postBookingConfirm := endpoint.New("POST", "/booking_requests/{id}/confirm", "Confirm a hold",
+		endpoint.Handler(handlers.PostBookingConfirm),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Description("Turn an unexpired hold into a booking request, 'pending' unless the state 'booked' is given"),
+		endpoint.Body(dbmodels.BookingHoldConfirm{}, "hold confirmation body", false),
+		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "SUCCESS"),
+		endpoint.Tags("Booking Requests CAPI"),
+	)
+	getRoomAvailability := endpoint.New("GET", "/rooms/{id}/availability", "Get room availability",
+		endpoint.Handler(handlers.GetRoomAvailability),
+		endpoint.Description("Get the occupancy and the remaining capacity of a room slot by slot, a room which isn't shared has a capacity of 1, "+
+			"the slots closed by a blackout or a maintenance block have no remaining capacity"),
+		endpoint.Path("id", "string", "uuid", "room id"),
+		endpoint.Query("from", "string", "date-time", "start of the period, now by default", false),
+		endpoint.Query("to", "string", "date-time", "end of the period, a week after its start by default, 31 days after it at most", false),
+		endpoint.Response(http.StatusOK, []dbmodels.RoomAvailabilitySlot{}, "Success"),
+		endpoint.Tags("Rooms CAPI"),
+	)
+	searchRooms := endpoint.New("GET", "/rooms/search", "Search available rooms",
+		endpoint.Handler(handlers.SearchRooms),
+		endpoint.Description("Get the rooms open, not blocked and with room left for the party during the whole period, "+
+			"the rooms wasting the least capacity come first"),
+		endpoint.Query("start_time", "string", "date-time", "start of the period", true),
+		endpoint.Query("end_time", "string", "date-time", "end of the period", true),
+		endpoint.Query("hotel_id", "string", "uuid", "hotel of the rooms", false),
+		endpoint.Query("data_center_id", "string", "uuid", "data center of the hotels of the rooms", false),
+		endpoint.Query("type", "string", "", "room type like 'double'", false),
+		endpoint.Query("party_size", "integer", "", "number of persons, 1 by default", false),
+		endpoint.Query("limit", "integer", "", "largest number of rooms returned, 100 at most", false),
+		endpoint.Response(http.StatusOK, []dbmodels.RoomSearchResult{}, "Success"),
+		endpoint.Tags("Rooms CAPI"),
+	)
+	getRoomQuote := endpoint.New("GET", "/rooms/{id}/quote", "Get a price quote",
+		endpoint.Handler(handlers.GetRoomQuote),
+		endpoint.Description("Price a stay in a room with its rate plan, the amounts are in the minor unit of the currency"),
+		endpoint.Path("id", "string", "uuid", "room id"),
+		endpoint.Query("start_time", "string", "date-time", "start of the stay", true),
+		endpoint.Query("end_time", "string", "date-time", "end of the stay", true),
+		endpoint.Response(http.StatusOK, dbmodels.Quote{}, "Success"),
+		endpoint.Tags("Rooms CAPI"),
+	)
+	postWaitlistEntry := endpoint.New("POST", "/waitlist", "Join a waitlist",
+		endpoint.Handler(handlers.PostWaitlistEntry),
+		endpoint.Description("Wait for a slot of a room, a hold is offered and notified to the email once the slot is freed"),
+		endpoint.Body(dbmodels.WaitlistEntryPost{}, "waitlist entry post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.WaitlistEntry{}, "SUCCESS"),
+		endpoint.Tags("Waitlist CAPI"),
+	)
+	getWaitlistEntries := endpoint.New("GET", "/waitlist", "Get waitlist entries",
+		endpoint.Handler(handlers.GetWaitlistEntries),
+		endpoint.Description("Get the waitlist entries of the customer, the offered ones reference their hold"),
+		endpoint.Response(http.StatusOK, []dbmodels.WaitlistEntry{}, "Success"),
+		endpoint.Tags("Waitlist CAPI"),
+	)
+	deleteWaitlistEntry := endpoint.New("DELETE", "/waitlist/{id}", "Leave a waitlist",
+		endpoint.Handler(handlers.DeleteWaitlistEntry),
+		endpoint.Description("Remove a waitlist entry of the customer"),
+		endpoint.Path("id", "string", "uuid", "waitlist entry id"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful waitlist entry removal"),
+		endpoint.Tags("Waitlist CAPI"),
+	)
+	postCalendarToken := endpoint.New("POST", "/calendar_tokens", "Create a calendar feed token",
+		endpoint.Handler(handlers.PostCalendarToken),
+		endpoint.Description("Create a token for the iCalendar feed /bookings/calendar/{token}.ics of the customer"),
+		endpoint.Response(http.StatusOK, dbmodels.CalendarToken{}, "SUCCESS"),
+		endpoint.Tags("Calendar CAPI"),
+	)
+	getCalendarTokens := endpoint.New("GET", "/calendar_tokens", "Get calendar feed tokens",
+		endpoint.Handler(handlers.GetCalendarTokens),
+		endpoint.Description("Get the calendar feed tokens of the customer"),
+		endpoint.Response(http.StatusOK, []dbmodels.CalendarToken{}, "Success"),
+		endpoint.Tags("Calendar CAPI"),
+	)
+	deleteCalendarToken := endpoint.New("DELETE", "/calendar_tokens/{token}", "Revoke a calendar feed token",
+		endpoint.Handler(handlers.DeleteCalendarToken),
+		endpoint.Description("Revoke a calendar feed token, its feed is not served anymore"),
+		endpoint.Path("token", "string", "", "calendar feed token"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful token revocation"),
+		endpoint.Tags("Calendar CAPI"),
+	)
+	postDocumentCustomer := endpoint.New("POST", "/booking_requests/{id}/document", "Upload the booking document",
+		endpoint.Handler(handlers.PostBookingDocumentCAPI),
+		endpoint.Description("Upload the document of a booking request as the multipart field 'file', it replaces the previous one"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Response(http.StatusOK, dbmodels.BookingDocument{}, "SUCCESS"),
+		endpoint.Tags("Booking Documents CAPI"),
+	)
+	getDocumentCustomer := endpoint.New("GET", "/booking_requests/{id}/document", "Download the booking document",
+		endpoint.Handler(handlers.GetBookingDocumentCAPI),
+		endpoint.Description("Download the document of a booking request"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Response(http.StatusOK, "", "Success"),
+		endpoint.Tags("Booking Documents CAPI"),
+	)
+	postMessageCustomer := endpoint.New("POST", "/booking_requests/{id}/messages", "Post a booking message",
+		endpoint.Handler(handlers.PostBookingMessageCAPI),
+		endpoint.Description("Add a message to the thread of a booking request, as JSON or as a multipart form with the "+
+			"'body' field and the optional 'file' attachment, the other side is notified"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Body(dbmodels.BookingMessagePost{}, "booking message body", false),
+		endpoint.Response(http.StatusOK, dbmodels.BookingMessage{}, "SUCCESS"),
+		endpoint.Tags("Booking Messages CAPI"),
+	)
+	getMessagesCustomer := endpoint.New("GET", "/booking_requests/{id}/messages", "Get booking messages",
+		endpoint.Handler(handlers.GetBookingMessagesCAPI),
+		endpoint.Description("Get the thread of a booking request, the first message first, "+
+			"the messages of the other side are marked as read"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Response(http.StatusOK, []dbmodels.BookingMessage{}, "Success"),
+		endpoint.Tags("Booking Messages CAPI"),
+	)
+	getMessageAttachmentCustomer := endpoint.New("GET", "/booking_requests/{id}/messages/{message_id}/attachment",
+		"Download a booking message attachment",
+		endpoint.Handler(handlers.GetBookingMessageAttachmentCAPI),
+		endpoint.Description("Download the attachment of a message of a booking request"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Path("message_id", "string", "uuid", "booking message id"),
+		endpoint.Response(http.StatusOK, "", "Success"),
+		endpoint.Tags("Booking Messages CAPI"),
+	)
 	return []*swagger.Endpoint{
 		getBookingsCustomer,
 		getBookingCustomer,
 		postBookingCustomer,
 		patchBookingCustomer,
+		postBookingHold,
+		postBookingConfirm,
+		getCancellationPreview,
+		getPaymentCustomer,
+		postDocumentCustomer,
+		getDocumentCustomer,
+		postMessageCustomer,
+		getMessagesCustomer,
+		getMessageAttachmentCustomer,
+		postCalendarToken,
+		getCalendarTokens,
+		deleteCalendarToken,
+		getRoomAvailability,
+		searchRooms,
+		getRoomQuote,
+		postWaitlistEntry,
+		getWaitlistEntries,
+		deleteWaitlistEntry,
 	}
 }
 func bookingsPAPI() []*swagger.Endpoint {
@@ -111,7 +290,8 @@ func bookingsPAPI() []*swagger.Endpoint {
 
 	getBookingsProvider := endpoint.New("GET", "/provider/booking_requests", "Get booking request",
 		endpoint.Handler(handlers.GetBookingsPAPI),
-		endpoint.Description("Get all the booking requests per customer"),
+		endpoint.Description("Get all the booking requests per customer, 'Accept: text/csv' or "+
+			"'Accept: application/vnd.openxmlformats-officedocument.spreadsheetml.sheet' exports all the filtered bookings"),
 		endpoint.QueryMap(map[string]swagger.Parameter{
 			"page": {
 				Type:        "integer",
@@ -167,14 +347,26 @@ func bookingsPAPI() []*swagger.Endpoint {
 				Nullable:    true,
 				Description: "comma separated list of states {'draft', 'cancelled', 'approved', 'pending', 'pending_resp', 'rejected', 'completed'}",
 			},
+			"fields": {
+				Type:        "string",
+				Nullable:    true,
+				Description: "comma separated list of booking fields to return, e.g. 'id,state,start_time'",
+			},
+			"embed": {
+				Type:        "string",
+				Nullable:    true,
+				Description: "comma separated list of resources to include in the bookings {'room', 'hotel'}",
+			},
 		}),
 		endpoint.Response(http.StatusOK, []dbmodels.Booking{}, "Success"),
 		endpoint.Tags("Booking Requests PAPI"),
 	)
 	getBookingProvider := endpoint.New("GET", "/provider/booking_requests/{id}", "Get booking request",
-		endpoint.Handler(handlers.GetBooking),
+		endpoint.Handler(handlers.GetBookingPAPI),
 		endpoint.Description("Get booking request by its ID"),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Query("fields", "string", "", "comma separated list of booking fields to return", false),
+		endpoint.Query("embed", "string", "", "comma separated list of resources to include {'room', 'hotel'}", false),
 		endpoint.Path("id", "string", "uuid", "booking request id"),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "Success"),
 		endpoint.Tags("Booking Requests PAPI"),
@@ -182,7 +374,7 @@ func bookingsPAPI() []*swagger.Endpoint {
 	postBookingProvider := endpoint.New("POST", "/provider/booking_requests", "Create a booking request",
 		endpoint.Handler(handlers.PostBookingPAPI),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
-		endpoint.Description("Create a booking request"),
+		endpoint.Description("Create a booking request, a series of bookings is created when an rrule is given"),
 		endpoint.Body(dbmodels.BookingPost{}, "booking request post body", true),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "SUCCESS"),
 		endpoint.Tags("Booking Requests PAPI"),
@@ -191,28 +383,378 @@ func bookingsPAPI() []*swagger.Endpoint {
 		endpoint.Handler(handlers.PatchBookingPAPI),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
 		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Query("scope", "string", "", "for bookings of a series: 'occurrence' (default), 'following' or 'series'", false),
 		endpoint.Description("Update a booking request"),
 		endpoint.Body(dbmodels.BookingPatch{}, "booking request patch body", true),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "UPDATED"),
 		endpoint.Tags("Booking Requests PAPI"),
 	)
+	postDocumentProvider := endpoint.New("POST", "/provider/booking_requests/{id}/document", "Upload the booking document",
+		endpoint.Handler(handlers.PostBookingDocumentPAPI),
+		endpoint.Description("Upload the document of a booking request as the multipart field 'file', it replaces the previous one"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Response(http.StatusOK, dbmodels.BookingDocument{}, "SUCCESS"),
+		endpoint.Tags("Booking Documents PAPI"),
+	)
+	getDocumentProvider := endpoint.New("GET", "/provider/booking_requests/{id}/document", "Download the booking document",
+		endpoint.Handler(handlers.GetBookingDocumentPAPI),
+		endpoint.Description("Download the document of a booking request"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Response(http.StatusOK, "", "Success"),
+		endpoint.Tags("Booking Documents PAPI"),
+	)
+	postMessageProvider := endpoint.New("POST", "/provider/booking_requests/{id}/messages", "Post a booking message",
+		endpoint.Handler(handlers.PostBookingMessagePAPI),
+		endpoint.Description("Add a message to the thread of a booking request, as JSON or as a multipart form with the "+
+			"'body' field and the optional 'file' attachment, the other side is notified"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Body(dbmodels.BookingMessagePost{}, "booking message body", false),
+		endpoint.Response(http.StatusOK, dbmodels.BookingMessage{}, "SUCCESS"),
+		endpoint.Tags("Booking Messages PAPI"),
+	)
+	getMessagesProvider := endpoint.New("GET", "/provider/booking_requests/{id}/messages", "Get booking messages",
+		endpoint.Handler(handlers.GetBookingMessagesPAPI),
+		endpoint.Description("Get the thread of a booking request, the first message first, "+
+			"the messages of the other side are marked as read"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Response(http.StatusOK, []dbmodels.BookingMessage{}, "Success"),
+		endpoint.Tags("Booking Messages PAPI"),
+	)
+	getMessageAttachmentProvider := endpoint.New("GET", "/provider/booking_requests/{id}/messages/{message_id}/attachment",
+		"Download a booking message attachment",
+		endpoint.Handler(handlers.GetBookingMessageAttachmentPAPI),
+		endpoint.Description("Download the attachment of a message of a booking request"),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Path("message_id", "string", "uuid", "booking message id"),
+		endpoint.Response(http.StatusOK, "", "Success"),
+		endpoint.Tags("Booking Messages PAPI"),
+	)
+	streamBookingsProvider := endpoint.New("GET", "/provider/booking_requests/stream", "Stream booking changes",
+		endpoint.Handler(handlers.GetBookingsStreamPAPI),
+		endpoint.Description("Server-Sent Events stream of the booking create, update and delete events, "+
+			"the Last-Event-ID header resumes the stream after the given event"),
+		endpoint.QueryMap(map[string]swagger.Parameter{
+			"data_center": {
+				Type:        "array",
+				Items:       &itmUUID,
+				Nullable:    true,
+				Description: "comma separated list of data-centers uuids",
+			},
+			"customer": {
+				Type:        "array",
+				Items:       &itmUUID,
+				Nullable:    true,
+				Description: "comma separated list of customer uuids",
+			},
+			"states": {
+				Type:        "string",
+				Nullable:    true,
+				Description: "comma separated list of states {'draft', 'cancelled', 'booked', 'pending', 'pending_resp', 'rejected', 'completed'}",
+			},
+		}),
+		endpoint.Response(http.StatusOK, "", "text/event-stream"),
+		endpoint.Tags("Booking Requests PAPI"),
+	)
+	postWebhook := endpoint.New("POST", "/provider/webhooks", "Register a webhook endpoint",
+		endpoint.Handler(handlers.PostWebhookPAPI),
+		endpoint.Description("Register a URL receiving the booking events signed with HMAC-SHA256 of the secret, "+
+			"an empty event_types list subscribes to all of 'booking.created', 'booking.updated', 'booking.deleted', 'booking.expired', 'booking.moved', 'booking.escalated', 'booking.message'"),
+		endpoint.Body(dbmodels.WebhookEndpointPost{}, "webhook endpoint post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.WebhookEndpoint{}, "SUCCESS"),
+		endpoint.Tags("Webhooks PAPI"),
+	)
+	getWebhooks := endpoint.New("GET", "/provider/webhooks", "Get webhook endpoints",
+		endpoint.Handler(handlers.GetWebhooksPAPI),
+		endpoint.Description("Get the webhook endpoints of the provider"),
+		endpoint.Response(http.StatusOK, []dbmodels.WebhookEndpoint{}, "Success"),
+		endpoint.Tags("Webhooks PAPI"),
+	)
+	deleteWebhook := endpoint.New("DELETE", "/provider/webhooks/{id}", "Delete webhook endpoint",
+		endpoint.Handler(handlers.DeleteWebhookPAPI),
+		endpoint.Description("Delete a webhook endpoint with its delivery log"),
+		endpoint.Path("id", "string", "uuid", "webhook endpoint id"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful webhook endpoint removal"),
+		endpoint.Tags("Webhooks PAPI"),
+	)
+	getWebhookDeliveries := endpoint.New("GET", "/provider/webhooks/{id}/deliveries", "Get webhook deliveries",
+		endpoint.Handler(handlers.GetWebhookDeliveriesPAPI),
+		endpoint.Description("Get the delivery log of a webhook endpoint, newest first"),
+		endpoint.Path("id", "string", "uuid", "webhook endpoint id"),
+		endpoint.Query("status", "string", "", "'pending', 'delivered' or 'failed'", false),
+		endpoint.Query("page", "integer", "", "Page-number to show as first page", false),
+		endpoint.Query("per_page", "integer", "", "Number of records on a page", false),
+		endpoint.Response(http.StatusOK, []dbmodels.WebhookDelivery{}, "Success"),
+		endpoint.Tags("Webhooks PAPI"),
+	)
+	postWebhookReplay := endpoint.New("POST", "/provider/webhook_deliveries/{id}/replay", "Replay webhook delivery",
+		endpoint.Handler(handlers.PostWebhookReplayPAPI),
+		endpoint.Description("Send a webhook delivery again, e.g. a failed one"),
+		endpoint.Path("id", "string", "uuid", "webhook delivery id"),
+		endpoint.Response(http.StatusAccepted, dbmodels.WebhookDelivery{}, "ACCEPTED"),
+		endpoint.Tags("Webhooks PAPI"),
+	)
+	getPaymentProvider := endpoint.New("GET", "/provider/booking_requests/{id}/payment", "Get the booking payment",
+		endpoint.Handler(handlers.GetBookingPaymentPAPI),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Description("Get the payment of a booking request with its authorize, capture, refund and void transactions"),
+		endpoint.Response(http.StatusOK, dbmodels.Payment{}, "Success"),
+		endpoint.Tags("Payments PAPI"),
+	)
+	postBookingMoveProvider := endpoint.New("POST", "/provider/booking_requests/{id}/move", "Move a booking request",
+		endpoint.Handler(handlers.PostBookingMovePAPI),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Description("Move a booking request to another room of the provider, of the same type unless any_type is set, "+
+			"the room must be open and free for the party. With swap_with the two booking requests exchange their rooms. "+
+			"The price is kept, the move is recorded and notified to the customer"),
+		endpoint.Body(dbmodels.BookingMove{}, "booking move body", true),
+		endpoint.Response(http.StatusOK, []dbmodels.Booking{}, "SUCCESS"),
+		endpoint.Tags("Booking Requests PAPI"),
+	)
+	getBookingMovesProvider := endpoint.New("GET", "/provider/booking_requests/{id}/moves", "Get booking request moves",
+		endpoint.Handler(handlers.GetBookingMovesPAPI),
+		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
+		endpoint.Path("id", "string", "uuid", "booking request id"),
+		endpoint.Description("Get the room moves of a booking request of the provider, the first one first"),
+		endpoint.Response(http.StatusOK, []dbmodels.BookingRoomMove{}, "Success"),
+		endpoint.Tags("Booking Requests PAPI"),
+	)
+	postRatePlan := endpoint.New("POST", "/provider/rate_plans", "Create a rate plan",
+		endpoint.Handler(handlers.PostRatePlanPAPI),
+		endpoint.Description("Create the rate plan of a room, or of a room type of a hotel, with rates in the minor unit "+
+			"of the currency per 'night' or per started 'hour', the weekend nights are the ones of Friday and Saturday"),
+		endpoint.Body(dbmodels.RatePlanPost{}, "rate plan post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.RatePlan{}, "SUCCESS"),
+		endpoint.Tags("Rate Plans PAPI"),
+	)
+	getRatePlans := endpoint.New("GET", "/provider/rate_plans", "Get rate plans",
+		endpoint.Handler(handlers.GetRatePlansPAPI),
+		endpoint.Description("Get the rate plans of the provider"),
+		endpoint.Response(http.StatusOK, []dbmodels.RatePlan{}, "Success"),
+		endpoint.Tags("Rate Plans PAPI"),
+	)
+	putRatePlan := endpoint.New("PUT", "/provider/rate_plans/{id}", "Replace a rate plan",
+		endpoint.Handler(handlers.PutRatePlanPAPI),
+		endpoint.Description("Replace a rate plan, the prices of the existing bookings don't change"),
+		endpoint.Path("id", "string", "uuid", "rate plan id"),
+		endpoint.Body(dbmodels.RatePlanPost{}, "rate plan post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.RatePlan{}, "UPDATED"),
+		endpoint.Tags("Rate Plans PAPI"),
+	)
+	deleteRatePlan := endpoint.New("DELETE", "/provider/rate_plans/{id}", "Delete a rate plan",
+		endpoint.Handler(handlers.DeleteRatePlanPAPI),
+		endpoint.Description("Delete a rate plan, the prices of the existing bookings are kept"),
+		endpoint.Path("id", "string", "uuid", "rate plan id"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful rate plan removal"),
+		endpoint.Tags("Rate Plans PAPI"),
+	)
+	postCancellationPolicy := endpoint.New("POST", "/provider/cancellation_policies", "Create a cancellation policy",
+		endpoint.Handler(handlers.PostCancellationPolicyPAPI),
+		endpoint.Description("Create the cancellation policy of a room, or of a whole hotel, charging fee_percent of the price "+
+			"of the bookings the customers cancel less than free_until_hours hours before their start"),
+		endpoint.Body(dbmodels.CancellationPolicyPost{}, "cancellation policy post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.CancellationPolicy{}, "SUCCESS"),
+		endpoint.Tags("Cancellation Policies PAPI"),
+	)
+	getCancellationPolicies := endpoint.New("GET", "/provider/cancellation_policies", "Get cancellation policies",
+		endpoint.Handler(handlers.GetCancellationPoliciesPAPI),
+		endpoint.Description("Get the cancellation policies of the provider"),
+		endpoint.Response(http.StatusOK, []dbmodels.CancellationPolicy{}, "Success"),
+		endpoint.Tags("Cancellation Policies PAPI"),
+	)
+	putCancellationPolicy := endpoint.New("PUT", "/provider/cancellation_policies/{id}", "Replace a cancellation policy",
+		endpoint.Handler(handlers.PutCancellationPolicyPAPI),
+		endpoint.Description("Replace a cancellation policy, the fees of the cancelled bookings don't change"),
+		endpoint.Path("id", "string", "uuid", "cancellation policy id"),
+		endpoint.Body(dbmodels.CancellationPolicyPost{}, "cancellation policy post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.CancellationPolicy{}, "UPDATED"),
+		endpoint.Tags("Cancellation Policies PAPI"),
+	)
+	deleteCancellationPolicy := endpoint.New("DELETE", "/provider/cancellation_policies/{id}", "Delete a cancellation policy",
+		endpoint.Handler(handlers.DeleteCancellationPolicyPAPI),
+		endpoint.Description("Delete a cancellation policy"),
+		endpoint.Path("id", "string", "uuid", "cancellation policy id"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful cancellation policy removal"),
+		endpoint.Tags("Cancellation Policies PAPI"),
+	)
+	postRoomBlock := endpoint.New("POST", "/provider/room_blocks", "Create a room block",
+		endpoint.Handler(handlers.PostRoomBlockPAPI),
+		endpoint.Description("Close a room, or the rooms of the provider in a hotel, for a 'blackout' or a 'maintenance', "+
+			"an rrule like FREQ=WEEKLY;BYDAY=SU;COUNT=10 repeats the block in the local time of the hotel. "+
+			"The overlapping bookings have to be cancelled or moved first"),
+		endpoint.Body(dbmodels.RoomBlockPost{}, "room block post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.RoomBlock{}, "SUCCESS"),
+		endpoint.Tags("Room Blocks PAPI"),
+	)
+	getRoomBlocks := endpoint.New("GET", "/provider/room_blocks", "Get room blocks",
+		endpoint.Handler(handlers.GetRoomBlocksPAPI),
+		endpoint.Description("Get the room blocks of the provider"),
+		endpoint.Response(http.StatusOK, []dbmodels.RoomBlock{}, "Success"),
+		endpoint.Tags("Room Blocks PAPI"),
+	)
+	putRoomBlock := endpoint.New("PUT", "/provider/room_blocks/{id}", "Replace a room block",
+		endpoint.Handler(handlers.PutRoomBlockPAPI),
+		endpoint.Description("Replace a room block"),
+		endpoint.Path("id", "string", "uuid", "room block id"),
+		endpoint.Body(dbmodels.RoomBlockPost{}, "room block post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.RoomBlock{}, "UPDATED"),
+		endpoint.Tags("Room Blocks PAPI"),
+	)
+	deleteRoomBlock := endpoint.New("DELETE", "/provider/room_blocks/{id}", "Delete a room block",
+		endpoint.Handler(handlers.DeleteRoomBlockPAPI),
+		endpoint.Description("Delete a room block, its rooms are open for bookings again"),
+		endpoint.Path("id", "string", "uuid", "room block id"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful room block removal"),
+		endpoint.Tags("Room Blocks PAPI"),
+	)
+	getApprovalQueue := endpoint.New("GET", "/provider/approval_queue", "Get the approval queue",
+		endpoint.Handler(handlers.GetApprovalQueuePAPI),
+		endpoint.Description("Get the booking requests awaiting the answer of the provider, the oldest first, with the deadline "+
+			"set by the approval SLA of their hotel"),
+		endpoint.Query("hotel_id", "string", "uuid", "hotel of the rooms of the booking requests", false),
+		endpoint.Query("page", "integer", "", "Page-number to show as first page", false),
+		endpoint.Query("per_page", "integer", "", "Number of records on a page", false),
+		endpoint.Response(http.StatusOK, []dbmodels.ApprovalQueueItem{}, "Success"),
+		endpoint.Tags("Approvals PAPI"),
+	)
+	getApprovalMetrics := endpoint.New("GET", "/provider/approval_metrics", "Get approval metrics",
+		endpoint.Handler(handlers.GetApprovalMetricsPAPI),
+		endpoint.Description("Get per hotel the number of booking requests answered in the period and the latencies of the answers "+
+			"in seconds, from the time the requests started awaiting the provider"),
+		endpoint.Query("from", "string", "date-time", "start of the period, 30 days before its end by default", false),
+		endpoint.Query("to", "string", "date-time", "end of the period, now by default", false),
+		endpoint.Response(http.StatusOK, []dbmodels.ApprovalMetrics{}, "Success"),
+		endpoint.Tags("Approvals PAPI"),
+	)
+	getOccupancyReport := endpoint.New("GET", "/provider/reports/occupancy", "Get the occupancy report",
+		endpoint.Handler(handlers.GetOccupancyReportPAPI),
+		endpoint.Description("Get the occupancy of the rooms of the provider per room or per hotel, per day, week or month of "+
+			"the period in the local time of the hotels. The blocked time of the opening hours is reported apart and isn't "+
+			"bookable, the occupancy is the time occupied by the booked requests, weighted by the persons of the shared rooms, "+
+			"over the bookable time. 'Accept: text/csv' exports the report"),
+		endpoint.Query("from", "string", "date", "first local date of the period, 30 days before its end by default", false),
+		endpoint.Query("to", "string", "date", "local date following the period, tomorrow by default", false),
+		endpoint.Query("hotel_id", "string", "uuid", "hotel id", false),
+		endpoint.Query("group_by", "string", "", "'hotel', the default, or 'room'", false),
+		endpoint.Query("interval", "string", "", "'day', the default, 'week' or 'month'", false),
+		endpoint.Response(http.StatusOK, []dbmodels.OccupancyReportRow{}, "Success"),
+		endpoint.Tags("Reports PAPI"),
+	)
+	getBookingStatsReport := endpoint.New("GET", "/provider/reports/bookings", "Get the booking statistics report",
+		endpoint.Handler(handlers.GetBookingStatsReportPAPI),
+		endpoint.Description("Count by state the booking requests of the provider requested in the period, per room or per hotel, "+
+			"with the part of the booked requests which were cancelled and the lead times in seconds from the request to the start. "+
+			"'Accept: text/csv' exports the report"),
+		endpoint.Query("from", "string", "date", "first local date of the period, 30 days before its end by default", false),
+		endpoint.Query("to", "string", "date", "local date following the period, tomorrow by default", false),
+		endpoint.Query("hotel_id", "string", "uuid", "hotel id", false),
+		endpoint.Query("group_by", "string", "", "'hotel', the default, or 'room'", false),
+		endpoint.Response(http.StatusOK, []dbmodels.BookingStatsReportRow{}, "Success"),
+		endpoint.Tags("Reports PAPI"),
+	)
+	getLeadTimeReport := endpoint.New("GET", "/provider/reports/lead_times", "Get the lead time report",
+		endpoint.Handler(handlers.GetLeadTimeReportPAPI),
+		endpoint.Description("Get the distribution of the lead times, from the request to the start, of the booking requests of "+
+			"the provider requested in the period, per room or per hotel. 'Accept: text/csv' exports the report"),
+		endpoint.Query("from", "string", "date", "first local date of the period, 30 days before its end by default", false),
+		endpoint.Query("to", "string", "date", "local date following the period, tomorrow by default", false),
+		endpoint.Query("hotel_id", "string", "uuid", "hotel id", false),
+		endpoint.Query("group_by", "string", "", "'hotel', the default, or 'room'", false),
+		endpoint.Response(http.StatusOK, []dbmodels.LeadTimeReportRow{}, "Success"),
+		endpoint.Tags("Reports PAPI"),
+	)
+	postApprovalSLA := endpoint.New("POST", "/provider/approval_slas", "Create an approval SLA",
+		endpoint.Handler(handlers.PostApprovalSLAPAPI),
+		endpoint.Description("Give the provider response_minutes minutes to answer the booking requests of the rooms of a hotel, "+
+			"the requests still awaiting an answer after it are escalated to escalation_email or rejected, as set by the "+
+			"breach_action 'escalate' or 'reject'"),
+		endpoint.Body(dbmodels.ApprovalSLAPost{}, "approval SLA post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.ApprovalSLA{}, "SUCCESS"),
+		endpoint.Tags("Approvals PAPI"),
+	)
+	getApprovalSLAs := endpoint.New("GET", "/provider/approval_slas", "Get approval SLAs",
+		endpoint.Handler(handlers.GetApprovalSLAsPAPI),
+		endpoint.Description("Get the approval SLAs of the provider"),
+		endpoint.Response(http.StatusOK, []dbmodels.ApprovalSLA{}, "Success"),
+		endpoint.Tags("Approvals PAPI"),
+	)
+	putApprovalSLA := endpoint.New("PUT", "/provider/approval_slas/{id}", "Replace an approval SLA",
+		endpoint.Handler(handlers.PutApprovalSLAPAPI),
+		endpoint.Description("Replace an approval SLA, the deadlines of the awaiting booking requests follow it"),
+		endpoint.Path("id", "string", "uuid", "approval SLA id"),
+		endpoint.Body(dbmodels.ApprovalSLAPost{}, "approval SLA post body", true),
+		endpoint.Response(http.StatusOK, dbmodels.ApprovalSLA{}, "UPDATED"),
+		endpoint.Tags("Approvals PAPI"),
+	)
+	deleteApprovalSLA := endpoint.New("DELETE", "/provider/approval_slas/{id}", "Delete an approval SLA",
+		endpoint.Handler(handlers.DeleteApprovalSLAPAPI),
+		endpoint.Description("Delete an approval SLA"),
+		endpoint.Path("id", "string", "uuid", "approval SLA id"),
+		endpoint.Response(http.StatusNoContent, "Success", "Successful approval SLA removal"),
+		endpoint.Tags("Approvals PAPI"),
+	)
 	return []*swagger.Endpoint{
 		getBookingsProvider,
+		streamBookingsProvider,
 		getBookingProvider,
 		postBookingProvider,
 		patchBookingProvider,
+		postDocumentProvider,
+		getDocumentProvider,
+		postMessageProvider,
+		getMessagesProvider,
+		getMessageAttachmentProvider,
+		postWebhook,
+		getWebhooks,
+		deleteWebhook,
+		getWebhookDeliveries,
+		postWebhookReplay,
+		getPaymentProvider,
+		postBookingMoveProvider,
+		getBookingMovesProvider,
+		postRatePlan,
+		getRatePlans,
+		putRatePlan,
+		deleteRatePlan,
+		postCancellationPolicy,
+		getCancellationPolicies,
+		putCancellationPolicy,
+		deleteCancellationPolicy,
+		postRoomBlock,
+		getRoomBlocks,
+		putRoomBlock,
+		deleteRoomBlock,
+		getApprovalQueue,
+		getApprovalMetrics,
+		postApprovalSLA,
+		getApprovalSLAs,
+		putApprovalSLA,
+		deleteApprovalSLA,
+		getOccupancyReport,
+		getBookingStatsReport,
+		getLeadTimeReport,
 	}
 }
 func bookingsSAPI() []*swagger.Endpoint {
 	postBookingSystem := endpoint.New("POST", "/system/booking_requests", "Create a booking request",
 		endpoint.Handler(handlers.PostBookingSAPI),
 		endpoint.Query("dc_id", "string", "uuid", "data_center id", false),
-		endpoint.Description("Create a booking request"),
+		endpoint.Description("Create a booking request, a series of bookings is created when an rrule is given"),
 		endpoint.Body(dbmodels.BookingPost{}, "booking request post body", true),
 		endpoint.Response(http.StatusOK, dbmodels.Booking{}, "SUCCESS"),
 		endpoint.Tags("Booking Requests SAPI"),
 	)
 
+	postBookingsBulkSystem := endpoint.New("POST", "/system/booking_requests/bulk", "Bulk booking request operations",
+		endpoint.Handler(handlers.PostBookingsBulkSAPI),
+		endpoint.Query("atomic", "boolean", "", "apply all the operations or none of them", false),
+		endpoint.Description("Create, update and delete booking requests in one request, the result of every operation is reported"),
+		endpoint.Body(dbmodels.BulkRequest{}, "bulk operations body", true),
+		endpoint.Response(http.StatusMultiStatus, dbmodels.BulkResponse{}, "MULTI-STATUS"),
+		endpoint.Tags("Booking Requests SAPI"),
+	)
+
 	deleteBookingSystem := endpoint.New("DELETE", "/restricted/booking_requests/{id}", "Delete booking request",
 		endpoint.Handler(handlers.DeleteBookingSystem),
 		endpoint.Description("Delete booking request by its ID"),
@@ -222,8 +764,30 @@ func bookingsSAPI() []*swagger.Endpoint {
 		endpoint.Tags("Booking Requests SAPI"),
 	)
 
+	postImportSystem := endpoint.New("POST", "/system/import", "Import rooms or bookings",
+		endpoint.Handler(handlers.PostImportSAPI),
+		endpoint.Description("Import rooms or bookings from the CSV (with a header of field names) or JSON Lines request body, the failing lines are reported"),
+		endpoint.Query("kind", "string", "", "'rooms' or 'bookings'", true),
+		endpoint.Query("format", "string", "", "'csv' or 'jsonl' (default)", false),
+		endpoint.Query("dry_run", "boolean", "", "validate the records without writing them", false),
+		endpoint.Response(http.StatusOK, importer.Report{}, "SUCCESS"),
+		endpoint.Tags("Import SAPI"),
+	)
+
+	patchHotelSystem := endpoint.New("PATCH", "/system/hotels/{id}", "Update a hotel",
+		endpoint.Handler(handlers.PatchHotelSAPI),
+		endpoint.Description("Set the IANA time zone of the hotel (like Europe/Budapest), the opening hours of its rooms are local times of it"),
+		endpoint.Path("id", "string", "uuid", "hotel id"),
+		endpoint.Body(dbmodels.HotelPatch{}, "hotel patch body", true),
+		endpoint.Response(http.StatusOK, dbmodels.Hotel{}, "SUCCESS"),
+		endpoint.Tags("Hotels SAPI"),
+	)
+
 	return []*swagger.Endpoint{
 		postBookingSystem,
+		postBookingsBulkSystem,
 		deleteBookingSystem,
+		postImportSystem,
+		patchHotelSystem,
 	}
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bookings/handlers"
 	"bookings/middleware"
 	"net/http"
 	"regexp"
@@ -87,6 +88,11 @@ func CreateRouter() *gin.Engine {
 	sapi := CreateSwaggerSAPI()
 	enableCors := false
 
+	// the calendar feed is polled by calendar applications, the token in the path authenticates it
+	r.GET("/bookings/calendar/:token", handlers.GetCalendarFeed)
+	// the payment providers post the results of their operations, the body is signed
+	r.POST("/bookings/payments/callback/:provider", handlers.PostPaymentCallback)
+
 	org := r.Group("", checkHeaders(), sv.SwaggerValidator(capi), sv.SwaggerValidator(papi), sv.SwaggerValidator(sapi), middleware.Pagination(), middleware.ValidateUUIDs())
 
 	org.GET("/bookings/bookings-doc", gin.WrapH(capi.Handler(enableCors)))
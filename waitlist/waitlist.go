@@ -0,0 +1,73 @@
+// Package waitlist offers the slots freed by cancelled, rejected or deleted bookings and
+// expired holds to the waitlisted customers
+package waitlist
+
+import (
+	"bookings/dbmodels"
+	"bookings/events"
+	"bookings/notifier"
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config defines the waitlist configuration options
+type Config struct {
+	OfferDuration time.Duration `envconfig:"waitlist_offer_duration" default:"30m"`
+}
+
+// Offerer creates a hold for the first waitlisted customer of a freed slot and notifies them
+type Offerer struct {
+	conf   Config
+	mailer *notifier.Notifier
+}
+
+// New creates an offerer
+func New(conf Config, mailer *notifier.Notifier) *Offerer {
+	return &Offerer{conf: conf, mailer: mailer}
+}
+
+// Start subscribes to the booking events
+func (o *Offerer) Start() {
+	events.SubscribeLocal(func(ev events.BookingEvent) {
+		if frees(ev) {
+			go o.offer(ev)
+		}
+	})
+}
+
+// frees tells whether the event may free the slot of the booking
+func frees(ev events.BookingEvent) bool {
+	switch ev.Type {
+	case events.BookingExpired, events.BookingDeleted, events.BookingMoved:
+		return true
+	case events.BookingUpdated:
+		return ev.State != ev.PreviousState && (ev.State == "cancelled" || ev.State == "rejected")
+	}
+	return false
+}
+
+func (o *Offerer) offer(ev events.BookingEvent) {
+	var b dbmodels.Booking
+	if err := json.Unmarshal(ev.Booking, &b); err != nil {
+		log.Errorf("Failed to decode booking of event %s: %s", ev.Type, err)
+		return
+	}
+	roomID := b.RoomID
+	if b.MovedFrom != nil {
+		// a moved booking frees its previous room
+		roomID = *b.MovedFrom
+	}
+	offers, err := dbmodels.OfferWaitlistedSlots(roomID, b.StartTime, b.EndTime, o.conf.OfferDuration)
+	if err != nil {
+		log.Errorf("Failed to offer the slot of booking %s to the waitlist: %s", b.ID, err)
+		return
+	}
+	for _, offer := range offers {
+		log.Infof("Offered hold %s to waitlist entry %s", offer.Hold.ID, offer.Entry.ID)
+		if msg, ok := o.mailer.Compose(notifier.KindOffered, &offer.Hold, ""); ok {
+			o.mailer.Enqueue(msg)
+		}
+	}
+}
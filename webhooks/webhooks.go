@@ -0,0 +1,170 @@
+package webhooks
+
+import (
+	"bookings/dbmodels"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Headers of the webhook requests
+const (
+	HeaderEvent     = "X-Bookings-Event"
+	HeaderDelivery  = "X-Bookings-Delivery"
+	HeaderTimestamp = "X-Bookings-Timestamp"
+	HeaderSignature = "X-Bookings-Signature"
+)
+
+// Config defines the webhook dispatcher configuration options
+type Config struct {
+	PollInterval time.Duration `envconfig:"webhook_poll_interval" default:"2s"`
+	Timeout      time.Duration `envconfig:"webhook_timeout" default:"10s"`
+	MaxAttempts  int           `envconfig:"webhook_max_attempts" default:"8"`
+	BaseDelay    time.Duration `envconfig:"webhook_base_delay" default:"30s"`
+	MaxDelay     time.Duration `envconfig:"webhook_max_delay" default:"6h"`
+	BatchSize    int           `envconfig:"webhook_batch_size" default:"20"`
+}
+
+// Dispatcher sends the due webhook deliveries, failed ones are retried with exponential
+// backoff. The deliveries are recorded with the booking events, in their transaction.
+type Dispatcher struct {
+	conf   Config
+	client *http.Client
+	done   chan struct{}
+}
+
+// New creates a dispatcher
+func New(conf Config) *Dispatcher {
+	return &Dispatcher{
+		conf:   conf,
+		client: &http.Client{Timeout: conf.Timeout, Transport: publicTransport()},
+		done:   make(chan struct{}),
+	}
+}
+
+// publicTransport connects to public addresses only, whatever the endpoint host resolves to
+// when the request is sent, the redirects included
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !dbmodels.IsPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// Start starts sending the deliveries
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+// Stop stops sending the deliveries
+func (d *Dispatcher) Stop() {
+	close(d.done)
+}
+
+func (d *Dispatcher) run() {
+	ticker := time.NewTicker(d.conf.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			d.sendDue()
+		}
+	}
+}
+
+func (d *Dispatcher) sendDue() {
+	// the lease covers the requests of the batch, after it another instance may retry them
+	lease := d.conf.Timeout*time.Duration(d.conf.BatchSize) + time.Minute
+	due, err := dbmodels.ClaimWebhookDeliveries(d.conf.BatchSize, lease)
+	if err != nil {
+		log.Errorf("Failed to claim webhook deliveries: %s", err)
+		return
+	}
+	for _, delivery := range due {
+		statusCode, err := d.send(delivery)
+		var retryAt *time.Time
+		if err != nil && delivery.Attempts+1 < d.conf.MaxAttempts {
+			t := time.Now().UTC().Add(d.backoff(delivery.Attempts))
+			retryAt = &t
+		}
+		if err != nil {
+			log.Warnf("Webhook delivery %s to %s failed: %s", delivery.ID, delivery.URL, err)
+		}
+		if err = dbmodels.RecordWebhookAttempt(delivery.ID, statusCode, err, retryAt); err != nil {
+			log.Errorf("Failed to record webhook delivery %s: %s", delivery.ID, err)
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.conf.BaseDelay
+	for i := 0; i < attempts && delay < d.conf.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.conf.MaxDelay {
+		delay = d.conf.MaxDelay
+	}
+	return delay
+}
+
+// Sign returns the signature of a payload, the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) send(delivery dbmodels.DueWebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookings-webhooks")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"bookings/dbmodels"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"type":"booking.created"}`)
+	// echo -n '1546300800.{"type":"booking.created"}' | openssl dgst -sha256 -hmac whsec
+	want := "sha256=e13979d6f9a76d8c5b3a811e17a1b2c9349d08a9783669c971bd8f8273471e54"
+	if got := Sign("whsec", 1546300800, payload); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("whsec", 1546300801, payload) == want {
+		t.Error("the signature doesn't cover the timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(Config{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute})
+	for attempts, want := range []time.Duration{
+		30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute,
+	} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestSendRefusesLoopbackEndpoints(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer srv.Close()
+
+	d := New(Config{Timeout: time.Second})
+	delivery := dbmodels.DueWebhookDelivery{URL: srv.URL, Secret: "secret"}
+	delivery.ID = uuid.NewV4()
+	delivery.EventType = "booking.created"
+	delivery.Payload = []byte(`{}`)
+	if _, err := d.send(delivery); err == nil {
+		t.Fatal("expected the loopback endpoint to be refused")
+	}
+	if hit {
+		t.Error("the loopback endpoint was reached")
+	}
+}